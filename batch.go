@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Floats per instance: rect (pos, size), sprite offset and color.
+const batchStride = 12
+
+// Where each instance attribute starts, in floats. They are attributes 1 to 3
+// of batch_vertex.glsl, in this order.
+var batchAttributes = [3]int{0, 4, 8}
+
+// Quads per draw call before the batch is flushed early.
+const batchSize = 4096
+
+// BatchRenderer collects quads into a per-instance buffer and draws all the
+// quads sharing a texture with one instanced call. It only handles models
+// built by getModel, that is a translation and a scale.
+type BatchRenderer struct {
+	shader    Shader
+	instances uint32
+	window    *sdl.Window
+
+	texture uint32
+	data    []float32
+	count   int32
+}
+
+// NewBatchRenderer compiles the batch shaders and sets up the buffers. A
+// shader that fails to build is returned as an error so the caller can fall
+// back to GLRenderer.
+func NewBatchRenderer(window *sdl.Window) (*BatchRenderer, error) {
+	shader, err := LoadShader("batch_vertex.glsl", "batch_fragment.glsl")
+
+	if err != nil {
+		return nil, fmt.Errorf("batch shader: %w", err)
+	}
+
+	r := &BatchRenderer{
+		shader: shader,
+		window: window,
+		data:   make([]float32, 0, batchSize*batchStride),
+	}
+
+	r.shader.Use()
+
+	points := []float32{
+		0, 0, //
+		0, 1, //
+		1, 1, //
+		1, 0, //
+	}
+
+	indices := []int32{
+		0, 1, 2, //
+		0, 2, 3, //
+	}
+
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 2*bit32, nil)
+	gl.EnableVertexAttribArray(0)
+
+	gl.BufferData(gl.ARRAY_BUFFER, len(points)*bit32, gl.Ptr(points), gl.STATIC_DRAW)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*bit32, gl.Ptr(indices), gl.STATIC_DRAW)
+
+	gl.GenBuffers(1, &r.instances)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.instances)
+	gl.BufferData(gl.ARRAY_BUFFER, cap(r.data)*bit32, nil, gl.STREAM_DRAW)
+
+	for i, start := range batchAttributes {
+		location := uint32(1 + i)
+
+		gl.VertexAttribPointer(location, 4, gl.FLOAT, false, batchStride*bit32,
+			gl.PtrOffset(start*bit32))
+		gl.EnableVertexAttribArray(location)
+		gl.VertexAttribDivisor(location, 1)
+	}
+
+	r.shader.SetInt("uImage", 0)
+	r.shader.SetMat4("uProjection", Ortho(W, H))
+
+	return r, nil
+}
+
+func (r *BatchRenderer) Clear(color vec4) {
+	r.Flush()
+
+	gl.ClearColor(color.x, color.y, color.z, color.w)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+func (r *BatchRenderer) Bind(t Texture) {
+	if t.id == r.texture {
+		return
+	}
+
+	r.Flush()
+	r.texture = t.id
+
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
+}
+
+func (r *BatchRenderer) Draw(model Mat4, offset vec4, color vec4) {
+	r.data = PackInstance(r.data, model, offset, color)
+
+	if r.count++; r.count == batchSize {
+		r.Flush()
+	}
+}
+
+// PackInstance appends the batchStride floats of one quad to data, laid out
+// as batchAttributes says: the rect read back from the translation and scale
+// of model, the sprite offset and the color.
+func PackInstance(data []float32, model Mat4, offset vec4, color vec4) []float32 {
+	m := model.data
+
+	return append(data,
+		m[0][3], m[1][3], m[0][0], m[1][1],
+		offset.x, offset.y, offset.z, offset.w,
+		color.x, color.y, color.z, color.w,
+	)
+}
+
+// Flush draws whatever has been collected so far.
+func (r *BatchRenderer) Flush() {
+	if r.count == 0 {
+		return
+	}
+
+	r.shader.Use()
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.instances)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(r.data)*bit32, gl.Ptr(r.data))
+
+	gl.DrawElementsInstanced(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0), r.count)
+
+	r.data = r.data[:0]
+	r.count = 0
+}
+
+func (r *BatchRenderer) Present() {
+	r.Flush()
+	r.window.GLSwap()
+}
@@ -0,0 +1,12 @@
+#version 330 core
+
+in vec2 texCoords;
+in vec4 tint;
+
+uniform sampler2D uImage;
+
+out vec4 color;
+
+void main () {
+  color = texture (uImage, texCoords) * tint;
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPackInstance(t *testing.T) {
+	offset, color := vec4{.1, .2, .3, .4}, vec4{1, .5, .25, 1}
+
+	data := PackInstance(nil, getModel(vec2{10, 20}, vec2{30, 40}), offset, color)
+	data = PackInstance(data, getModel(vec2{-8, 0}, vec2{16, 16}), vec4{}, vec4{1, 1, 1, 1})
+
+	if len(data) != 2*batchStride {
+		t.Fatalf("packed %d floats for two quads, want %d", len(data), 2*batchStride)
+	}
+
+	want := [3][]float32{
+		{10, 20, 30, 40},
+		{offset.x, offset.y, offset.z, offset.w},
+		{color.x, color.y, color.z, color.w},
+	}
+
+	for i, start := range batchAttributes {
+		if got := data[start : start+4]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("attribute %d at %d is %v, want %v", i+1, start, got, want[i])
+		}
+	}
+
+	if last := batchAttributes[len(batchAttributes)-1] + 4; last != batchStride {
+		t.Errorf("attributes end at %d, stride is %d", last, batchStride)
+	}
+
+	if rect := data[batchStride : batchStride+4]; !reflect.DeepEqual(rect, []float32{-8, 0, 16, 16}) {
+		t.Errorf("second quad starts with %v, want its rect", rect)
+	}
+}
@@ -0,0 +1,17 @@
+#version 330 core
+
+layout (location = 0) in vec2 aPos;
+layout (location = 1) in vec4 aRect;
+layout (location = 2) in vec4 aOffset;
+layout (location = 3) in vec4 aColor;
+
+uniform mat4 uProjection;
+
+out vec2 texCoords;
+out vec4 tint;
+
+void main () {
+  texCoords = aPos * aOffset.zw + aOffset.xy;
+  tint = aColor;
+  gl_Position = uProjection * vec4(aPos * aRect.zw + aRect.xy, 0.0f, 1.0f);
+}
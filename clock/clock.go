@@ -0,0 +1,48 @@
+// Package clock keeps time for timers and the frame loop without depending on
+// SDL, so the same code runs in the client, the server and tests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock counts milliseconds from some fixed start, like SDL_GetTicks. It
+// wraps after 49 days, which Timer copes with.
+type Clock interface {
+	Ticks() uint32
+}
+
+// Monotonic counts from when it was made using the monotonic clock of the
+// time package.
+type Monotonic struct {
+	start time.Time
+}
+
+func NewMonotonic() Monotonic {
+	return Monotonic{time.Now()}
+}
+
+func (m Monotonic) Ticks() uint32 {
+	return uint32(time.Since(m.start).Milliseconds())
+}
+
+// Fake only moves when told to.
+type Fake struct {
+	mutex sync.Mutex
+	ms    uint32
+}
+
+func (f *Fake) Ticks() uint32 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.ms
+}
+
+func (f *Fake) Advance(ms uint32) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.ms += ms
+}
@@ -0,0 +1,179 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// step is one Update of a timer after advancing the clock by ms.
+type step struct {
+	ms    uint32
+	state uint32
+}
+
+func TestTimer(t *testing.T) {
+	tests := []struct {
+		name   string
+		config uint32
+		steps  []step
+	}{
+		{"simple", SIMPLE, []step{
+			{0, START | JUST},
+			{10, START},
+			{80, START},
+			{10, DONE | JUST},
+			{10, DONE},
+			{1000, DONE},
+		}},
+		{"two way", TWO_WAY, []step{
+			{0, START | JUST},
+			{100, WAIT | JUST},
+			{50, WAIT},
+			{50, DONE | JUST},
+			{100, DONE},
+		}},
+		{"loop", LOOP, []step{
+			{0, START | JUST},
+			{100, DONE | JUST},
+			{0, START | JUST},
+			{0, START},
+			{150, DONE | JUST},
+			{0, START | JUST},
+		}},
+		{"ping pong", TWO_WAY | LOOP, []step{
+			{0, START | JUST},
+			{100, WAIT | JUST},
+			{100, DONE | JUST},
+			{0, START | JUST},
+		}},
+		{"late update", SIMPLE, []step{
+			{500, START | JUST},
+			{99, START},
+			{1, DONE | JUST},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &Fake{}
+			timer := NewTimer(clock, test.config, 100)
+
+			if timer.State() != NONE {
+				t.Fatalf("new timer is in state %d", timer.State())
+			}
+
+			for i, s := range test.steps {
+				clock.Advance(s.ms)
+				timer.Update()
+
+				if timer.State() != s.state {
+					t.Errorf("step %d: state %d, want %d", i, timer.State(), s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestTimerProgress(t *testing.T) {
+	tests := []struct {
+		config uint32
+		after  []uint32
+		want   []float32
+	}{
+		{SIMPLE, []uint32{0, 25, 50, 25, 100}, []float32{0, .25, .75, 1, 1}},
+		{TWO_WAY, []uint32{50, 50, 25, 75}, []float32{.5, 1, .75, 0}},
+	}
+
+	for _, test := range tests {
+		clock := &Fake{}
+		timer := NewTimer(clock, test.config, 100)
+		timer.Update()
+
+		for i, ms := range test.after {
+			clock.Advance(ms)
+
+			if got := timer.Progress(); got != test.want[i] {
+				t.Errorf("config %d step %d: progress %v, want %v", test.config, i,
+					got, test.want[i])
+			}
+
+			timer.Update()
+		}
+	}
+}
+
+func TestTicks(t *testing.T) {
+	clock := &Fake{}
+	ticks := NewTicks(clock)
+
+	clock.Advance(35)
+	ticks.Update()
+
+	if ticks.Frame() != .035 {
+		t.Errorf("frame took %v, want .035", ticks.Frame())
+	}
+
+	steps := 0
+
+	for ticks.Step() {
+		steps++
+	}
+
+	if steps != 3 {
+		t.Errorf("35ms made %d steps of 10ms, want 3", steps)
+	}
+
+	clock.Advance(5000)
+	ticks.Update()
+
+	if ticks.Frame() != .25 {
+		t.Errorf("a 5s frame counted as %v, want it capped to .25", ticks.Frame())
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	clock := NewMonotonic()
+	before := clock.Ticks()
+
+	time.Sleep(5 * time.Millisecond)
+
+	if after := clock.Ticks(); after < before+5 {
+		t.Errorf("5ms sleep moved the clock from %d to %d", before, after)
+	}
+}
+
+func TestTicksRolling(t *testing.T) {
+	clock := &Fake{}
+	ticks := NewTicks(clock)
+
+	for i := 0; i < samples; i++ {
+		clock.Advance(50)
+		ticks.Update()
+	}
+
+	if fps := ticks.FPS(); fps < 19.99 || fps > 20.01 {
+		t.Errorf("50ms frames run at %v fps, want 20", fps)
+	}
+
+	for i := 0; i < samples; i++ {
+		clock.Advance(10)
+		ticks.Update()
+
+		for ticks.Step() {
+		}
+	}
+
+	if fps := ticks.FPS(); fps < 99.9 || fps > 100.1 {
+		t.Errorf("after a full window of 10ms frames %v fps, want 100", fps)
+	}
+
+	clock.Advance(15)
+	ticks.Update()
+
+	for ticks.Step() {
+	}
+
+	if alpha := ticks.Alpha(); alpha < .49 || alpha > .51 {
+		t.Errorf("15ms into 10ms steps the alpha is %v, want .5", alpha)
+	}
+}
@@ -0,0 +1,184 @@
+package clock
+
+// Timer states. JUST is set alongside the others for the one update right
+// after the timer changed state.
+const NONE = 0
+
+const (
+	START        = 1
+	WAIT         = 2
+	DONE         = 4
+	JUST  uint32 = 8
+)
+
+// Timer configs.
+const (
+	SIMPLE  = 1
+	TWO_WAY = 2
+	LOOP    = 4
+)
+
+type Timer struct {
+	clock                                       Clock
+	state, config, delay, restartDelay, current uint32
+}
+
+// NewTimer makes a timer that starts on its first Update and lasts delay
+// milliseconds.
+func NewTimer(clock Clock, config uint32, delay uint32) Timer {
+	return Timer{
+		clock:   clock,
+		state:   NONE,
+		config:  config,
+		delay:   delay,
+		current: clock.Ticks(),
+	}
+}
+
+func (t *Timer) State() uint32 {
+	return t.state
+}
+
+func (t *Timer) Set(state uint32) {
+	t.state = state
+	t.current = t.clock.Ticks()
+}
+
+// Update moves the timer along: START lasts delay milliseconds, then a
+// TWO_WAY timer spends as long again in WAIT on its way back, then it is
+// DONE, or STARTs over with LOOP. JUST is set for the one update right after
+// each change.
+func (t *Timer) Update() {
+	diff := t.clock.Ticks() - t.current
+
+	if t.state == NONE || (t.state&DONE > 0 && t.config&LOOP > 0) {
+		t.Set(START | JUST)
+	} else if t.state&START > 0 && diff >= t.delay {
+		if t.config&TWO_WAY > 0 {
+			t.Set(WAIT | JUST)
+		} else {
+			t.Set(DONE | JUST)
+		}
+	} else if t.state&WAIT > 0 && diff >= t.delay {
+		t.Set(DONE | JUST)
+	} else if t.state&JUST > 0 {
+		t.state &= ^JUST
+	}
+}
+
+// Progress is how far through the timer is, from 0 to 1, and for TWO_WAY
+// timers back to 0.
+func (t Timer) Progress() float32 {
+	progress := float32(1)
+
+	if diff := t.clock.Ticks() - t.current; t.delay > 0 && diff < t.delay {
+		progress = float32(diff) / float32(t.delay)
+	}
+
+	switch {
+	case t.state&START > 0:
+		return progress
+	case t.state&WAIT > 0:
+		return 1 - progress
+	case t.state&DONE > 0 && t.config&TWO_WAY == 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// samples is how many frames the frame rate is averaged over.
+const samples = 60
+
+// Ticks measures frames. The time between two updates goes into an
+// accumulator that the fixed step simulation drains in steps of dt, what is
+// left over is how far the drawn frame is between two steps.
+type Ticks struct {
+	clock                                        Clock
+	frames                                       uint32
+	previous, current, delta, frame, accumulator float32
+
+	// times holds how long the last frames took, uncapped, as a ring.
+	times [samples]float32
+}
+
+func NewTicks(clock Clock) Ticks {
+	return Ticks{
+		clock:    clock,
+		frames:   0,
+		previous: 0, delta: 0.01, frame: 0, accumulator: 0,
+		current: float32(clock.Ticks()) / 1000,
+	}
+}
+
+// DT is the length of a simulation step in seconds.
+func (t Ticks) DT() float32 {
+	return t.delta
+}
+
+// Frame is how long the last frame took in seconds, at most a quarter.
+func (t Ticks) Frame() float32 {
+	return t.frame
+}
+
+// Step takes one dt out of the accumulator if there is that much in it.
+func (t *Ticks) Step() bool {
+	if t.accumulator < t.delta {
+		return false
+	}
+
+	t.accumulator -= t.delta
+
+	return true
+}
+
+// Alpha is how far into the next step the frame is once the steps are
+// taken, from 0 to 1, to interpolate between the last two steps with.
+func (t Ticks) Alpha() float32 {
+	return t.accumulator / t.delta
+}
+
+// FrameTime is the average frame time over the last frames, in seconds.
+func (t Ticks) FrameTime() float32 {
+	n := t.frames
+
+	if n > samples {
+		n = samples
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	sum := float32(0)
+
+	for _, time := range t.times[:n] {
+		sum += time
+	}
+
+	return sum / float32(n)
+}
+
+// FPS is the frame rate over the last frames.
+func (t Ticks) FPS() float32 {
+	if time := t.FrameTime(); time > 0 {
+		return 1 / time
+	}
+
+	return 0
+}
+
+func (t *Ticks) Update() {
+	t.previous = t.current
+	t.current = float32(t.clock.Ticks()) / 1000
+	t.frame = t.current - t.previous
+
+	t.times[t.frames%samples] = t.frame
+	t.frames++
+
+	if t.frame > 0.25 {
+		t.frame = 0.25
+	}
+
+	t.accumulator += t.frame
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"cardgame/game"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Connection is what the client needs to find a server and ask it for a game.
+type Connection struct {
+	protocol, host, port string
+	name                 string
+	size, k              int
+	variant              game.Variant
+	control              game.TimeControl
+}
+
+// Config is every client setting. It is built in layers, each one overriding
+// the last: the defaults, the config file, GOTACTOE_* environment variables
+// and finally the command line flags.
+type Config struct {
+	Connection
+
+	mode       string
+	room       string
+	difficulty string
+	replays    string
+	replay     string
+	fullscreen bool
+	scale      int
+	scaling    Scaling
+	bindings   Bindings
+
+	// path is the file the config was read from and is saved to.
+	path string
+}
+
+var config Config
+
+// envPrefix is put in front of the upper cased key to name its environment
+// variable, GOTACTOE_HOST for host.
+const envPrefix = "GOTACTOE_"
+
+// The game modes: show the title screen, go straight into a game, or watch
+// a replay.
+const (
+	modeMenu    = "menu"
+	modeOnline  = "online"
+	modeOffline = "offline"
+	modeReplay  = "replay"
+)
+
+const maxScale = 8
+
+func DefaultConfig() Config {
+	return Config{
+		Connection: Connection{protocol: "tcp", host: "127.0.0.1", port: "8080"},
+		mode:       modeMenu,
+		difficulty: game.Greedy.String(),
+		replays:    "replays",
+		scale:      1,
+		bindings:   DefaultBindings(),
+		path:       "config",
+	}
+}
+
+// setting is one key of the config file, also reachable as an environment
+// variable and as a flag of the same name.
+type setting struct {
+	key, usage string
+	get        func(c *Config) string
+	set        func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"protocol", "network to dial, tcp, tcp4 or tcp6",
+		func(c *Config) string { return c.protocol },
+		func(c *Config, v string) error { c.protocol = v; return nil }},
+	{"host", "server address",
+		func(c *Config) string { return c.host },
+		func(c *Config, v string) error { c.host = v; return nil }},
+	{"port", "server port",
+		func(c *Config) string { return c.port },
+		func(c *Config, v string) error { c.port = v; return nil }},
+	{"name", "player name shown to the opponent",
+		func(c *Config) string { return c.name },
+		func(c *Config, v string) error { c.name = v; return nil }},
+	{"mode", "menu, or online, offline or replay to skip the title screen",
+		func(c *Config) string { return c.mode },
+		func(c *Config, v string) error { c.mode = v; return nil }},
+	{"room", "online room to go straight to: a join code, or quick for a quick match",
+		func(c *Config) string { return c.room },
+		func(c *Config, v string) error { c.room = v; return nil }},
+	{"difficulty", "AI difficulty for offline games: random, greedy or perfect",
+		func(c *Config) string { return c.difficulty },
+		func(c *Config, v string) error { c.difficulty = v; return nil }},
+	{"replays", "directory finished games are saved to, empty to not save them",
+		func(c *Config) string { return c.replays },
+		func(c *Config, v string) error { c.replays = v; return nil }},
+	{"replay", "replay or game notation file to watch, empty for the latest one saved",
+		func(c *Config) string { return c.replay },
+		func(c *Config, v string) error { c.replay = v; return nil }},
+	{"size", "board size, 0 lets the server pick",
+		func(c *Config) string { return strconv.Itoa(c.size) },
+		func(c *Config, v string) (err error) { c.size, err = atoi(v); return }},
+	{"k", "marks in a row needed to win, 0 means the board size",
+		func(c *Config) string { return strconv.Itoa(c.k) },
+		func(c *Config, v string) (err error) { c.k, err = atoi(v); return }},
+	{"variant", "standard or ultimate",
+		func(c *Config) string { return c.variant.String() },
+		func(c *Config, v string) (err error) { c.variant, err = game.ParseVariant(v); return }},
+	{"time", "time control for online games: none, 300, 180+2 or 10/move; none lets the server pick",
+		func(c *Config) string { return c.control.String() },
+		func(c *Config, v string) (err error) { c.control, err = game.ParseTimeControl(v); return }},
+	{"fullscreen", "start in fullscreen",
+		func(c *Config) string { return strconv.FormatBool(c.fullscreen) },
+		func(c *Config, v string) (err error) { c.fullscreen, err = parseBool(v); return }},
+	{"scale", "window size as a multiple of 320x180",
+		func(c *Config) string { return strconv.Itoa(c.scale) },
+		func(c *Config, v string) (err error) { c.scale, err = atoi(v); return }},
+	{"scaling", "fit to scale the game by any amount, integer for whole steps only",
+		func(c *Config) string { return c.scaling.String() },
+		func(c *Config, v string) (err error) { c.scaling, err = ParseScaling(v); return }},
+}
+
+// Every action gets a bind_<action> key listing what triggers it.
+func init() {
+	for action := ActionPlaceMark; action < actionCount; action++ {
+		action := action
+
+		settings = append(settings, setting{"bind_" + action.String(),
+			"what triggers " + action.String() + ", like key:Return, mouse:left or pad:a",
+			func(c *Config) string { return c.bindings.Format(action) },
+			func(c *Config, v string) error { return c.bindings.Parse(action, v) }})
+	}
+}
+
+func atoi(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a whole number", v)
+	}
+
+	return n, nil
+}
+
+func parseBool(v string) (bool, error) {
+	b, err := strconv.ParseBool(v)
+
+	if err != nil {
+		return false, fmt.Errorf("%q is not true or false", v)
+	}
+
+	return b, nil
+}
+
+func lookup(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+
+	return setting{}, false
+}
+
+// Validate checks the settings against each other and the game rules.
+func (c Config) Validate() error {
+	if c.protocol == "" || c.host == "" {
+		return errors.New("protocol and host can not be empty")
+	}
+
+	if port, err := strconv.Atoi(c.port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port: %q is not a port number", c.port)
+	}
+
+	switch c.mode {
+	case modeMenu, modeOnline, modeOffline, modeReplay:
+	default:
+		return fmt.Errorf("mode: %q is not menu, online, offline or replay", c.mode)
+	}
+
+	if _, err := game.ParseDifficulty(c.difficulty); err != nil {
+		return fmt.Errorf("difficulty: %w", err)
+	}
+
+	if c.size != 0 {
+		if err := game.NewRules(c.size, c.k, c.variant).Validate(); err != nil {
+			return fmt.Errorf("size: %w", err)
+		}
+	} else if c.k != 0 {
+		return errors.New("k: needs a size as well")
+	}
+
+	if c.scale < 1 || c.scale > maxScale {
+		return fmt.Errorf("scale: %d is not between 1 and %d", c.scale, maxScale)
+	}
+
+	if len(c.name) > 32 {
+		return errors.New("name: longer than 32 characters")
+	}
+
+	return nil
+}
+
+// Rules returns the rules asked for, the classic game unless a size was set.
+func (c Config) Rules() game.Rules {
+	rules := game.Classic
+
+	if c.size != 0 {
+		rules = game.NewRules(c.size, c.k, game.Standard)
+	}
+
+	rules.Variant = c.variant
+
+	return rules
+}
+
+// Parse applies "key = value" lines to c. Blank lines and lines starting
+// with # are skipped.
+func (c *Config) Parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value, got %q", line, text)
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		s, ok := lookup(key)
+
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %q", line, key)
+		}
+
+		if err := s.set(c, value); err != nil {
+			return fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Dump writes c in the format Parse reads.
+func (c Config) Dump(w io.Writer) error {
+	for _, s := range settings {
+		if _, err := fmt.Fprintf(w, "%s = %s\n", s.key, s.get(&c)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save writes the given keys of c to the file it was read from. The lines
+// setting them are rewritten in place and the missing ones are added at the
+// end. Every other line, comments included, is left alone, so that settings
+// that came from the environment or the flags are not made permanent.
+func (c Config) Save(keys ...string) error {
+	data, err := os.ReadFile(c.path)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+
+	if text := strings.TrimSuffix(string(data), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+
+	wanted, written := map[string]bool{}, map[string]bool{}
+
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	line := func(key string) string {
+		s, _ := lookup(key)
+		return fmt.Sprintf("%s = %s", key, s.get(&c))
+	}
+
+	for i, text := range lines {
+		key, _, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+
+		if ok && wanted[key] {
+			lines[i] = line(key)
+			written[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		if !written[key] {
+			lines = append(lines, line(key))
+			written[key] = true
+		}
+	}
+
+	return os.WriteFile(c.path, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+// bindingKeys returns the bind_<action> keys of the actions bound differently
+// in b and old.
+func bindingKeys(b *Bindings, old *Bindings) []string {
+	var keys []string
+
+	for action := ActionPlaceMark; action < actionCount; action++ {
+		if b.Format(action) != old.Format(action) {
+			keys = append(keys, "bind_"+action.String())
+		}
+	}
+
+	return keys
+}
+
+// Environ applies the GOTACTOE_* variables found through getenv.
+func (c *Config) Environ(getenv func(string) string) error {
+	for _, s := range settings {
+		name := envPrefix + strings.ToUpper(s.key)
+
+		if value := getenv(name); value != "" {
+			if err := s.set(c, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// flagValue stores a flag until the file and the environment are applied, so
+// that flags win no matter where -config points.
+type flagValue struct {
+	key    string
+	isBool bool
+	values map[string]string
+}
+
+func (f flagValue) String() string {
+	return ""
+}
+
+func (f flagValue) Set(value string) error {
+	f.values[f.key] = value
+	return nil
+}
+
+func (f flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// LoadConfig builds the configuration from args, the command line without
+// the program name. It returns flag.ErrHelp after printing the config when
+// -print-config is given.
+func LoadConfig(args []string) (Config, error) {
+	c := DefaultConfig()
+
+	fs := flag.NewFlagSet("gotactoe", flag.ContinueOnError)
+
+	path := fs.String("config", "config", "path of the key = value config file")
+	dump := fs.Bool("print-config", false, "print the resulting config and exit")
+
+	values := map[string]string{}
+
+	for _, s := range settings {
+		fs.Var(flagValue{s.key, s.key == "fullscreen", values}, s.key, s.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	explicit := false
+
+	fs.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "config"
+	})
+
+	c.path = *path
+
+	file, err := os.Open(*path)
+
+	switch {
+	case err == nil:
+		err = c.Parse(file)
+		file.Close()
+
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", *path, err)
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return c, err
+	}
+
+	if err = c.Environ(os.Getenv); err != nil {
+		return c, err
+	}
+
+	for _, s := range settings {
+		if value, ok := values[s.key]; ok {
+			if err = s.set(&c, value); err != nil {
+				return c, fmt.Errorf("-%s: %w", s.key, err)
+			}
+		}
+	}
+
+	if err = c.Validate(); err != nil {
+		return c, err
+	}
+
+	if *dump {
+		c.Dump(os.Stdout)
+		return c, flag.ErrHelp
+	}
+
+	return c, nil
+}
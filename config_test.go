@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cardgame/game"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	file := "# comment\nhost = example.org\nport = 9000\nname = file\n\nscale = 2\n"
+
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOTACTOE_PORT", "9001")
+	t.Setenv("GOTACTOE_NAME", "env")
+
+	c, err := LoadConfig([]string{"-config", path, "-name", "flag", "-fullscreen"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.host != "example.org" || c.port != "9001" || c.name != "flag" ||
+		c.scale != 2 || !c.fullscreen || c.mode != modeMenu {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name, file string
+		args       []string
+		want       string
+	}{
+		{"missing file", "", []string{"-config", filepath.Join(dir, "none")}, "no such file"},
+		{"unknown key", "hots = x", nil, `line 1: unknown key "hots"`},
+		{"no equals", "host", nil, "line 1: expected key = value"},
+		{"bad number", "scale = big", nil, `line 1: scale: "big" is not a whole number`},
+		{"bad flag", "", []string{"-fullscreen=maybe"}, `-fullscreen: "maybe" is not true or false`},
+		{"bad port", "port = 0", nil, "port:"},
+		{"bad mode", "mode = lan", nil, "mode:"},
+		{"bad rules", "size = 3\nk = 4", nil, "size:"},
+		{"bad scale", "", []string{"-scale", "9"}, "scale: 9"},
+		{"bad time", "time = 0+5", nil, "line 1: time: invalid time control"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+
+			if test.file != "" {
+				path := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "-"))
+
+				if err := os.WriteFile(path, []byte(test.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				args = append([]string{"-config", path}, args...)
+			} else if args[0] != "-config" {
+				args = append([]string{"-config", os.DevNull}, args...)
+			}
+
+			_, err := LoadConfig(args)
+
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigDumpParses(t *testing.T) {
+	c := DefaultConfig()
+	c.name, c.size, c.k, c.scale = "someone", 5, 4, 3
+	c.control = game.TimeControl{Base: 3 * time.Minute, Increment: 2 * time.Second}
+
+	var dump strings.Builder
+
+	if err := c.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+
+	got := DefaultConfig()
+
+	if err := got.Parse(strings.NewReader(dump.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestConfigRules(t *testing.T) {
+	tests := []struct {
+		args []string
+		want game.Rules
+	}{
+		{nil, game.Classic},
+		{[]string{"-size", "4"}, game.Rules{Size: 4, K: 4}},
+		{[]string{"-size", "15", "-k", "5"}, game.Rules{Size: 15, K: 5}},
+		{[]string{"-variant", "ultimate"}, game.Rules{Size: 3, K: 3, Variant: game.Ultimate}},
+	}
+
+	for _, test := range tests {
+		c, err := LoadConfig(append([]string{"-config", os.DevNull}, test.args...))
+
+		if err != nil {
+			t.Errorf("%v: %v", test.args, err)
+			continue
+		}
+
+		if rules := c.Rules(); rules != test.want {
+			t.Errorf("%v: rules %v, want %v", test.args, rules, test.want)
+		}
+	}
+}
+
+func TestConfigSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	file := "# keep me\nhost = example.org\nbind_up = key:119\n# bind_down = key:115\n"
+
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOTACTOE_PORT", "9001")
+
+	c, err := LoadConfig([]string{"-config", path, "-name", "once"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := c.bindings
+	c.bindings.Bind(ActionUp, Key(sdl.K_k))
+	c.bindings.Bind(ActionDown, Key(sdl.K_q))
+
+	keys := bindingKeys(&c.bindings, &old)
+
+	if !reflect.DeepEqual(keys, []string{"bind_up", "bind_down"}) {
+		t.Errorf("changed keys %v, want bind_up and bind_down", keys)
+	}
+
+	if err = c.Save(keys...); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, _ := os.ReadFile(path)
+	want := "# keep me\nhost = example.org\nbind_up = " + c.bindings.Format(ActionUp) +
+		"\n# bind_down = key:115\nbind_down = " + c.bindings.Format(ActionDown) + "\n"
+
+	if string(saved) != want {
+		t.Errorf("saved\n%s\nwant\n%s", saved, want)
+	}
+
+	again, err := LoadConfig([]string{"-config", path})
+
+	if err != nil || again.bindings.Format(ActionDown) != c.bindings.Format(ActionDown) {
+		t.Errorf("reloaded %v, %v", again.bindings.Format(ActionDown), err)
+	}
+}
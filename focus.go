@@ -0,0 +1,136 @@
+package main
+
+import (
+	"cardgame/game"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// GridWidth is how many cells wide the board is on screen, counting every
+// sub-board of an ultimate board.
+func GridWidth() int {
+	if rules.Variant == game.Ultimate {
+		return 9
+	}
+
+	return rules.Size
+}
+
+// GridCell turns a column and row on screen into a cell index.
+func GridCell(x, y int) int {
+	if rules.Variant == game.Ultimate {
+		return (y/3*3+x/3)*9 + y%3*3 + x%3
+	}
+
+	return y*rules.Size + x
+}
+
+// GridPos is the inverse of GridCell.
+func GridPos(i int) (int, int) {
+	if rules.Variant == game.Ultimate {
+		sub, cell := i/9, i%9
+
+		return sub%3*3 + cell%3, sub/3*3 + cell/3
+	}
+
+	return i % rules.Size, i / rules.Size
+}
+
+// Focus is the cell picked with the keyboard, a gamepad or the mouse. It is
+// drawn with the hover border, so it stays visible while the mouse is
+// captured, and -1 hides it.
+type Focus struct {
+	cell int
+}
+
+// Set focuses cell i if the board has it.
+func (f *Focus) Set(i int, buttons []Button) {
+	if i >= 0 && i < len(buttons) {
+		f.cell = i
+	}
+
+	f.Apply(buttons)
+}
+
+// Move steps the focus by dx columns and dy rows, stopping at the edges. A
+// hidden focus shows up in the middle of the board instead.
+func (f *Focus) Move(dx, dy int, buttons []Button) {
+	width := GridWidth()
+
+	if f.cell < 0 || f.cell >= len(buttons) {
+		f.Set(GridCell(width/2, width/2), buttons)
+		return
+	}
+
+	x, y := GridPos(f.cell)
+	x, y = clamp(x+dx, 0, width-1), clamp(y+dy, 0, width-1)
+
+	f.Set(GridCell(x, y), buttons)
+}
+
+// Numpad focuses the cell laid out like numpad key n, 7 being the top left,
+// on a 3 by 3 board or inside the focused sub-board of an ultimate one. It
+// reports false on bigger boards, where the numpad only moves the focus.
+func (f *Focus) Numpad(n int, buttons []Button) bool {
+	x, y := (n-1)%3, 2-(n-1)/3
+
+	switch {
+	case rules.Variant == game.Ultimate:
+		sub := 4
+
+		if f.cell >= 0 && f.cell < len(buttons) {
+			sub = f.cell / 9
+		}
+
+		f.Set(sub*9+y*3+x, buttons)
+	case rules.Size == 3:
+		f.Set(GridCell(x, y), buttons)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// Apply outlines the focused button and clears the others.
+func (f Focus) Apply(buttons []Button) {
+	for i := range buttons {
+		if i == f.cell {
+			buttons[i].Hover()
+		} else {
+			buttons[i].UnHover()
+		}
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+
+	if v > max {
+		return max
+	}
+
+	return v
+}
+
+// controllers holds every plugged in game controller by instance id, SDL
+// only sends events for the opened ones.
+var controllers = map[sdl.JoystickID]*sdl.GameController{}
+
+// ControllerEvent opens controllers as they are plugged in and closes them
+// when they go away.
+func ControllerEvent(t *sdl.ControllerDeviceEvent) {
+	switch t.Type {
+	case sdl.CONTROLLERDEVICEADDED:
+		if c := sdl.GameControllerOpen(int(t.Which)); c != nil {
+			controllers[c.Joystick().InstanceID()] = c
+		}
+	case sdl.CONTROLLERDEVICEREMOVED:
+		if c, ok := controllers[t.Which]; ok {
+			c.Close()
+			delete(controllers, t.Which)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+type Difficulty int
+
+const (
+	Random Difficulty = iota
+	Greedy
+	Perfect
+)
+
+var difficultyNames = [...]string{"random", "greedy", "perfect"}
+
+func (d Difficulty) String() string {
+	if d < 0 || int(d) >= len(difficultyNames) {
+		return fmt.Sprintf("Difficulty(%d)", int(d))
+	}
+
+	return difficultyNames[d]
+}
+
+func ParseDifficulty(str string) (Difficulty, error) {
+	for i, name := range difficultyNames {
+		if name == str {
+			return Difficulty(i), nil
+		}
+	}
+
+	return Random, fmt.Errorf("unknown difficulty %q", str)
+}
+
+// BestMove picks a cell for the side whose turn it is on b. It returns -1 if
+// there is nothing left to play.
+//
+// Random plays any legal cell, Greedy takes a win when it sees one and
+// otherwise blocks the opponent, Perfect searches the game tree. The classic
+// board is searched to the end; bigger boards are searched searchDepth plies
+// deep and only around the marks already on the board.
+func BestMove(b Game, d Difficulty, rng *rand.Rand) int {
+	moves := b.Moves()
+
+	if len(moves) == 0 {
+		return -1
+	}
+
+	switch d {
+	case Greedy:
+		return greedy(b, moves, rng)
+	case Perfect:
+		return perfect(b, moves, rng)
+	default:
+		return moves[rng.Intn(len(moves))]
+	}
+}
+
+func greedy(b Game, moves []int, rng *rand.Rand) int {
+	side := b.Turn()
+
+	for _, s := range []Side{side, side.Other()} {
+		for _, i := range moves {
+			next := b.Clone()
+			next.place(s, i)
+
+			if next.Winner() == s {
+				return i
+			}
+		}
+	}
+
+	return moves[rng.Intn(len(moves))]
+}
+
+// perfect runs minimax with alpha-beta pruning and picks randomly among the
+// moves that share the best score so the AI does not always open the same way.
+func perfect(b Game, moves []int, rng *rand.Rand) int {
+	side := b.Turn()
+	best, bestScore := []int{}, -2*scoreWin
+	depth := searchDepth(b)
+
+	if depth > 0 {
+		moves = nearby(b, moves)
+	}
+
+	for _, i := range moves {
+		next := b.Clone()
+		next.Play(side, i)
+
+		score := -negamax(next, 1, depth, -2*scoreWin, 2*scoreWin)
+
+		if score > bestScore {
+			best, bestScore = []int{i}, score
+		} else if score == bestScore {
+			best = append(best, i)
+		}
+	}
+
+	return best[rng.Intn(len(best))]
+}
+
+const scoreWin = 1000
+
+// searchDepth returns how many plies perfect looks ahead, 0 meaning until the
+// game ends.
+func searchDepth(b Game) int {
+	switch {
+	case b.Rules().Variant == Ultimate:
+		return 3
+	case b.Len() <= 9:
+		return 0
+	case b.Len() <= 16:
+		return 4
+	default:
+		return 2
+	}
+}
+
+// nearby keeps the moves next to a mark already on the board, which is where
+// everything happens on a big board. An empty board gets its centre. Ultimate
+// already narrows the moves down to one sub-board most of the time.
+func nearby(g Game, moves []int) []int {
+	b, ok := g.(*Board)
+
+	if !ok {
+		return moves
+	}
+
+	size := b.rules.Size
+	result := []int{}
+
+next:
+	for _, i := range moves {
+		x, y := i%size, i/size
+
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				if j := b.Index(x+dx, y+dy); j >= 0 && b.cells[j] != None {
+					result = append(result, i)
+					continue next
+				}
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return []int{b.Index(size/2, size/2)}
+	}
+
+	return result
+}
+
+// negamax scores b from the point of view of the side to move. Faster wins
+// and slower losses score higher so the AI does not toy with its opponent.
+// Positions at the depth limit score as a draw.
+func negamax(b Game, depth int, limit int, alpha int, beta int) int {
+	switch b.Winner() {
+	case Draw:
+		return 0
+	case b.Turn():
+		return scoreWin - depth
+	case b.Turn().Other():
+		return depth - scoreWin
+	}
+
+	if limit > 0 && depth >= limit {
+		return 0
+	}
+
+	moves := b.Moves()
+
+	if limit > 0 {
+		moves = nearby(b, moves)
+	}
+
+	for _, i := range moves {
+		next := b.Clone()
+		next.Play(b.Turn(), i)
+
+		score := -negamax(next, depth+1, limit, -beta, -alpha)
+
+		if score > alpha {
+			alpha = score
+		}
+
+		if alpha >= beta {
+			break
+		}
+	}
+
+	return alpha
+}
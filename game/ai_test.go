@@ -0,0 +1,65 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBestMoveWinsAndBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		moves []int
+		want  int
+	}{
+		// X to move with 0 and 1, while O also threatens 5.
+		{"win", Classic, []int{0, 3, 1, 4}, 2},
+		// O to move, X threatens the diagonal.
+		{"block", Classic, []int{0, 1, 4}, 8},
+		// X to move on 5x5 connect-4 with three in the middle row.
+		{"win big", Rules{Size: 5, K: 4}, []int{10, 0, 11, 4, 12, 24}, 13},
+		// O to move, X has three down the second column.
+		{"block big", Rules{Size: 5, K: 4}, []int{1, 24, 6, 20, 11}, 16},
+	}
+
+	for _, d := range []Difficulty{Greedy, Perfect} {
+		for _, test := range tests {
+			rng := rand.New(rand.NewSource(1))
+
+			b := NewBoard(test.rules, X)
+			play(t, &b, test.moves...)
+
+			if i := BestMove(&b, d, rng); i != test.want {
+				t.Errorf("%v %s: played %d, want %d", d, test.name, i, test.want)
+			}
+		}
+	}
+}
+
+func TestBestMoveLegal(t *testing.T) {
+	rules := []Rules{
+		Classic,
+		{Size: 4, K: 3},
+		{Size: 7, K: 4},
+		{Size: 3, K: 3, Variant: Ultimate},
+	}
+
+	for _, r := range rules {
+		for _, d := range []Difficulty{Random, Greedy, Perfect} {
+			rng := rand.New(rand.NewSource(2))
+			g := New(r, X)
+
+			for g.Winner() == None {
+				i := BestMove(g, d, rng)
+
+				if err := g.Play(g.Turn(), i); err != nil {
+					t.Fatalf("%v %v: move %d after %v: %v", r, d, i, g.History(), err)
+				}
+			}
+
+			if BestMove(g, d, rng) != -1 {
+				t.Errorf("%v %v: moved after the game ended", r, d)
+			}
+		}
+	}
+}
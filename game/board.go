@@ -0,0 +1,305 @@
+// Package game holds the tic-tac-toe rules shared by the client and the
+// server. It knows nothing about rendering or the network.
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Side int8
+
+const (
+	None Side = -1
+	X    Side = 0
+	O    Side = 1
+	Draw Side = 2
+)
+
+// Other returns the opponent of s.
+func (s Side) Other() Side {
+	return 1 - s
+}
+
+var (
+	ErrOutOfRange  = errors.New("cell out of range")
+	ErrOccupied    = errors.New("cell already taken")
+	ErrGameOver    = errors.New("game is over")
+	ErrNotYourTurn = errors.New("not your turn")
+	ErrWrongBoard  = errors.New("move must go to the highlighted board")
+)
+
+// Board limits. 15x15 is the usual Gomoku board.
+const (
+	MinSize = 3
+	MaxSize = 15
+)
+
+// Rules describe a Size x Size board won by K marks in a row, or with the
+// Ultimate variant a 3x3 of classic boards.
+type Rules struct {
+	Size, K int
+	Variant Variant
+}
+
+var Classic = Rules{Size: 3, K: 3}
+
+// NewRules returns the rules of a size x size board won by k marks in a row,
+// or by a whole row when k is 0.
+func NewRules(size int, k int, variant Variant) Rules {
+	if k == 0 {
+		k = size
+	}
+
+	return Rules{Size: size, K: k, Variant: variant}
+}
+
+func (r Rules) Validate() error {
+	if r.Variant == Ultimate {
+		if r.Size != 3 || r.K != 3 {
+			return fmt.Errorf("ultimate is only played on 3x3 boards")
+		}
+
+		return nil
+	}
+
+	if r.Variant != Standard {
+		return fmt.Errorf("unknown variant %d", r.Variant)
+	}
+
+	if r.Size < MinSize || r.Size > MaxSize {
+		return fmt.Errorf("board size %d is not between %d and %d", r.Size,
+			MinSize, MaxSize)
+	}
+
+	if r.K < MinSize || r.K > r.Size {
+		return fmt.Errorf("win length %d is not between %d and %d", r.K,
+			MinSize, r.Size)
+	}
+
+	return nil
+}
+
+func (r Rules) String() string {
+	if r.Variant == Ultimate {
+		return r.Variant.String()
+	}
+
+	return fmt.Sprintf("%dx%d connect-%d", r.Size, r.Size, r.K)
+}
+
+// Directions scanned for lines: right, down, down-right and down-left.
+var directions = [4][2]int{{1, 0}, {0, 1}, {1, 1}, {-1, 1}}
+
+// Board is a square grid. Cells are indexed row by row, so cell i lives at
+// column i%Size and row i/Size. Boards share their cells when copied, use
+// Clone to get an independent one.
+type Board struct {
+	rules Rules
+	cells []Side
+	turn  Side
+
+	history
+}
+
+func NewBoard(rules Rules, first Side) Board {
+	b := Board{rules: rules, turn: first}
+
+	b.cells = make([]Side, rules.Size*rules.Size)
+
+	for i := range b.cells {
+		b.cells[i] = None
+	}
+
+	return b
+}
+
+func (b *Board) Clone() Game {
+	c := *b
+	c.cells = append([]Side(nil), b.cells...)
+	c.history = b.history.share()
+
+	return &c
+}
+
+func (b Board) Rules() Rules {
+	return b.rules
+}
+
+func (b Board) Len() int {
+	return len(b.cells)
+}
+
+func (b Board) Cell(i int) Side {
+	return b.cells[i]
+}
+
+func (b Board) Cells() []Side {
+	return b.cells
+}
+
+func (b Board) Turn() Side {
+	return b.turn
+}
+
+// Active is always -1, any cell of a plain board may be played.
+func (b Board) Active() int {
+	return -1
+}
+
+// Index returns the cell at column x and row y, or -1 if it is off the board.
+func (b Board) Index(x int, y int) int {
+	if x < 0 || y < 0 || x >= b.rules.Size || y >= b.rules.Size {
+		return -1
+	}
+
+	return y*b.rules.Size + x
+}
+
+// Check reports why cell i can not be played, or nil if it can.
+func (b Board) Check(i int) error {
+	if i < 0 || i >= len(b.cells) {
+		return ErrOutOfRange
+	}
+
+	if b.Winner() != None {
+		return ErrGameOver
+	}
+
+	if b.cells[i] != None {
+		return ErrOccupied
+	}
+
+	return nil
+}
+
+func (b Board) Legal(i int) bool {
+	return b.Check(i) == nil
+}
+
+// Play places the mark of side on cell i and passes the turn.
+func (b *Board) Play(side Side, i int) error {
+	if err := b.Check(i); err != nil {
+		return err
+	}
+
+	if side != b.turn {
+		return ErrNotYourTurn
+	}
+
+	b.push(step{side, i, -1})
+	b.place(side, i)
+
+	return nil
+}
+
+// Undo takes back the last move and keeps it for Redo.
+func (b *Board) Undo() error {
+	s, ok := b.undo()
+
+	if !ok {
+		return ErrNoUndo
+	}
+
+	b.cells[s.cell] = None
+	b.turn = s.side
+
+	return nil
+}
+
+// Redo plays the last move taken back again.
+func (b *Board) Redo() error {
+	s, ok := b.redo()
+
+	if !ok {
+		return ErrNoRedo
+	}
+
+	b.place(s.side, s.cell)
+
+	return nil
+}
+
+func (b *Board) place(side Side, i int) {
+	b.cells[i] = side
+	b.turn = side.Other()
+}
+
+// Moves lists every cell that is still playable.
+func (b Board) Moves() []int {
+	if b.Winner() != None {
+		return nil
+	}
+
+	moves := []int{}
+
+	for i, v := range b.cells {
+		if v == None {
+			moves = append(moves, i)
+		}
+	}
+
+	return moves
+}
+
+// Line returns the cells of the first K in a row found, or nil if there is
+// none.
+func (b Board) Line() []int {
+	size, k := b.rules.Size, b.rules.K
+
+	for i, v := range b.cells {
+		if v == None {
+			continue
+		}
+
+		for _, d := range directions {
+			x, y := i%size, i/size
+
+			if b.Index(x+d[0]*(k-1), y+d[1]*(k-1)) < 0 {
+				continue
+			}
+
+			line := []int{i}
+
+			for n := 1; n < k; n++ {
+				j := b.Index(x+d[0]*n, y+d[1]*n)
+
+				if b.cells[j] != v {
+					break
+				}
+
+				line = append(line, j)
+			}
+
+			if len(line) == k {
+				return line
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b Board) Full() bool {
+	for _, v := range b.cells {
+		if v == None {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Winner returns the side with K in a row, Draw for a full board with no
+// line, or None while the game is still going.
+func (b Board) Winner() Side {
+	if line := b.Line(); line != nil {
+		return b.cells[line[0]]
+	}
+
+	if b.Full() {
+		return Draw
+	}
+
+	return None
+}
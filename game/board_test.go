@@ -0,0 +1,131 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWinner(t *testing.T) {
+	tests := []struct {
+		name   string
+		moves  []int
+		winner Side
+		line   []int
+	}{
+		{"empty", nil, None, nil},
+		{"row", []int{3, 0, 4, 1, 5}, X, []int{3, 4, 5}},
+		{"column", []int{0, 1, 8, 4, 2, 7}, O, []int{1, 4, 7}},
+		{"diagonal", []int{0, 1, 4, 2, 8}, X, []int{0, 4, 8}},
+		{"anti-diagonal", []int{2, 0, 4, 1, 6}, X, []int{2, 4, 6}},
+		{"draw", []int{0, 1, 2, 4, 3, 5, 7, 6, 8}, Draw, nil},
+		{"going", []int{0, 4, 8}, None, nil},
+	}
+
+	for _, test := range tests {
+		b := NewBoard(Classic, X)
+		play(t, &b, test.moves...)
+
+		if w := b.Winner(); w != test.winner {
+			t.Errorf("%s: winner %v, want %v", test.name, w, test.winner)
+		}
+
+		if line := b.Line(); !reflect.DeepEqual(line, test.line) {
+			t.Errorf("%s: line %v, want %v", test.name, line, test.line)
+		}
+	}
+}
+
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []int
+		side  Side
+		cell  int
+		err   error
+	}{
+		{"legal", nil, X, 4, nil},
+		{"occupied", []int{4}, O, 4, ErrOccupied},
+		{"negative", nil, X, -1, ErrOutOfRange},
+		{"past the end", nil, X, 9, ErrOutOfRange},
+		{"out of turn", []int{4}, X, 0, ErrNotYourTurn},
+		{"after a win", []int{0, 3, 1, 4, 2}, O, 5, ErrGameOver},
+	}
+
+	for _, test := range tests {
+		b := NewBoard(Classic, X)
+		play(t, &b, test.moves...)
+
+		before := append([]Side(nil), b.Cells()...)
+
+		if err := b.Play(test.side, test.cell); err != test.err {
+			t.Errorf("%s: %v, want %v", test.name, err, test.err)
+		}
+
+		if test.err != nil && !reflect.DeepEqual(b.Cells(), before) {
+			t.Errorf("%s: refused move changed the board to %v", test.name, b.Cells())
+		}
+	}
+}
+
+func TestMovesAfterWin(t *testing.T) {
+	b := NewBoard(Classic, X)
+	play(t, &b, 0, 3, 1, 4, 2)
+
+	if moves := b.Moves(); len(moves) != 0 {
+		t.Errorf("moves %v after a win", moves)
+	}
+}
+
+func TestWinnerBig(t *testing.T) {
+	gomoku := Rules{Size: 15, K: 5}
+
+	tests := []struct {
+		name  string
+		marks []int
+		line  []int
+	}{
+		{"bottom edge", []int{220, 221, 222, 223, 224}, []int{220, 221, 222, 223, 224}},
+		{"right edge", []int{14, 29, 44, 59, 74}, []int{14, 29, 44, 59, 74}},
+		{"anti-diagonal", []int{70, 56, 42, 28, 14}, []int{14, 28, 42, 56, 70}},
+		{"four", []int{0, 1, 2, 3}, nil},
+		{"wrapped row", []int{13, 14, 15, 16, 17}, nil},
+		{"wrapped diagonal", []int{12, 28, 44, 60, 76}, nil},
+	}
+
+	for _, test := range tests {
+		b := NewBoard(gomoku, X)
+
+		for _, i := range test.marks {
+			b.cells[i] = X
+		}
+
+		if line := b.Line(); !reflect.DeepEqual(line, test.line) {
+			t.Errorf("%s: line %v, want %v", test.name, line, test.line)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		ok    bool
+	}{
+		{Classic, true},
+		{Rules{Size: 15, K: 5}, true},
+		{Rules{Size: 4, K: 4}, true},
+		{Rules{Size: 3, K: 3, Variant: Ultimate}, true},
+		{Rules{Size: 2, K: 2}, false},
+		{Rules{Size: 16, K: 5}, false},
+		{Rules{Size: 5, K: 2}, false},
+		{Rules{Size: 5, K: 6}, false},
+		{Rules{Size: 5, K: 0}, false},
+		{Rules{Size: 4, K: 3, Variant: Ultimate}, false},
+		{Rules{Size: 3, K: 3, Variant: 7}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.rules.Validate(); (err == nil) != test.ok {
+			t.Errorf("%+v: %v, want ok %v", test.rules, err, test.ok)
+		}
+	}
+}
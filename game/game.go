@@ -0,0 +1,100 @@
+package game
+
+import "fmt"
+
+type Variant uint8
+
+const (
+	Standard Variant = iota
+	Ultimate
+)
+
+var variantNames = [...]string{"standard", "ultimate"}
+
+func (v Variant) String() string {
+	if int(v) >= len(variantNames) {
+		return fmt.Sprintf("Variant(%d)", int(v))
+	}
+
+	return variantNames[v]
+}
+
+func ParseVariant(str string) (Variant, error) {
+	for i, name := range variantNames {
+		if name == str {
+			return Variant(i), nil
+		}
+	}
+
+	return Standard, fmt.Errorf("unknown variant %q", str)
+}
+
+// Game is what the server, the client and the AI need from a board,
+// whichever variant is being played.
+type Game interface {
+	Rules() Rules
+	Len() int
+	Cell(i int) Side
+	Cells() []Side
+	Turn() Side
+
+	// Active returns the sub-board the next move has to go to, or -1 when
+	// the mover is free to choose.
+	Active() int
+
+	Check(i int) error
+	Legal(i int) bool
+	Play(side Side, i int) error
+	Moves() []int
+
+	// History returns the cells played so far, oldest first. Undo takes
+	// back the last move and Redo plays it again, until another move is
+	// played. A restored game starts with no history.
+	History() []int
+	Undo() error
+	Redo() error
+
+	// Line returns the winning cells, or nil while nobody has won.
+	Line() []int
+	Winner() Side
+
+	Clone() Game
+
+	// place puts a mark down without any checks, for the AI to look ahead.
+	place(side Side, i int)
+}
+
+// New starts an empty game with first to move.
+func New(rules Rules, first Side) Game {
+	if rules.Variant == Ultimate {
+		return NewUltimateBoard(first)
+	}
+
+	b := NewBoard(rules, first)
+
+	return &b
+}
+
+// Restore rebuilds a game from a snapshot, e.g. the server state. active is
+// only meaningful for Ultimate.
+func Restore(rules Rules, cells []Side, turn Side, active int) Game {
+	if rules.Variant == Ultimate {
+		u := NewUltimateBoard(turn)
+
+		for i, v := range cells {
+			if v != None && i < u.Len() {
+				u.set(v, i)
+			}
+		}
+
+		u.active = active
+
+		return u
+	}
+
+	b := NewBoard(rules, turn)
+
+	copy(b.cells, cells)
+
+	return &b
+}
@@ -0,0 +1,105 @@
+package game
+
+import "errors"
+
+var (
+	ErrNoUndo = errors.New("no move to take back")
+	ErrNoRedo = errors.New("no move to play again")
+)
+
+// step is a move on the history stack, with the sub-board the mover was
+// sent to so that Ultimate can put it back.
+type step struct {
+	side   Side
+	cell   int
+	active int
+}
+
+// history is the move stack of a game: the moves played, oldest first, and
+// the moves taken back that Redo can play again, last taken back last.
+//
+// Clones share the arrays behind both stacks. Every slice is cut to its own
+// length when it shrinks or is shared, so that an append always copies
+// instead of writing over a move another copy can still see.
+type history struct {
+	played, undone []step
+}
+
+// push records a new move, which forgets the moves that could be redone.
+func (h *history) push(s step) {
+	h.played = append(h.played, s)
+	h.undone = nil
+}
+
+func (h *history) undo() (step, bool) {
+	n := len(h.played)
+
+	if n == 0 {
+		return step{}, false
+	}
+
+	s := h.played[n-1]
+	h.played = h.played[: n-1 : n-1]
+	h.undone = append(h.undone, s)
+
+	return s, true
+}
+
+func (h *history) redo() (step, bool) {
+	n := len(h.undone)
+
+	if n == 0 {
+		return step{}, false
+	}
+
+	s := h.undone[n-1]
+	h.undone = h.undone[: n-1 : n-1]
+	h.played = append(h.played, s)
+
+	return s, true
+}
+
+// share returns a copy of h that can grow apart from it.
+func (h history) share() history {
+	return history{
+		played: h.played[:len(h.played):len(h.played)],
+		undone: h.undone[:len(h.undone):len(h.undone)],
+	}
+}
+
+// History returns the cells played so far, oldest first.
+func (h history) History() []int {
+	cells := make([]int, len(h.played))
+
+	for i, s := range h.played {
+		cells[i] = s.cell
+	}
+
+	return cells
+}
+
+// Takeback takes back the last move side made and every move made after it,
+// so that it is side's turn again. Nothing is taken back if side has not
+// moved yet.
+func Takeback(g Game, side Side) error {
+	played := g.History()
+	last := -1
+
+	for i, cell := range played {
+		if g.Cell(cell) == side {
+			last = i
+		}
+	}
+
+	if last < 0 {
+		return ErrNoUndo
+	}
+
+	for n := len(played); n > last; n-- {
+		if err := g.Undo(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
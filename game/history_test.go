@@ -0,0 +1,116 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func play(t *testing.T, g Game, cells ...int) {
+	t.Helper()
+
+	for _, i := range cells {
+		if err := g.Play(g.Turn(), i); err != nil {
+			t.Fatalf("playing %d: %v", i, err)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	g := New(Classic, X)
+	play(t, g, 4, 0, 8)
+
+	if err := g.Undo(); err != nil || g.Cell(8) != None || g.Turn() != X {
+		t.Fatalf("undo: %v, cells %v, turn %v", err, g.Cells(), g.Turn())
+	}
+
+	g.Undo()
+
+	if err := g.Redo(); err != nil || g.Cell(0) != O || g.Turn() != X {
+		t.Fatalf("redo: %v, cells %v, turn %v", err, g.Cells(), g.Turn())
+	}
+
+	if !reflect.DeepEqual(g.History(), []int{4, 0}) {
+		t.Errorf("history %v, want [4 0]", g.History())
+	}
+
+	play(t, g, 2)
+
+	if err := g.Redo(); err != ErrNoRedo {
+		t.Errorf("redo after a new move: %v, want %v", err, ErrNoRedo)
+	}
+
+	for g.Undo() == nil {
+	}
+
+	if len(g.History()) != 0 || g.Turn() != X || g.Cell(4) != None {
+		t.Errorf("undoing everything left %v, turn %v", g.Cells(), g.Turn())
+	}
+}
+
+func TestCloneHistory(t *testing.T) {
+	g := New(Classic, X)
+	play(t, g, 4, 0, 8)
+	g.Undo()
+
+	c := g.Clone()
+	c.Undo()
+	play(t, c, 1)
+
+	g.Redo()
+
+	if !reflect.DeepEqual(g.History(), []int{4, 0, 8}) {
+		t.Errorf("original history %v after the clone moved on", g.History())
+	}
+
+	if !reflect.DeepEqual(c.History(), []int{4, 1}) || c.Redo() != ErrNoRedo {
+		t.Errorf("clone history %v", c.History())
+	}
+}
+
+func TestUndoUltimate(t *testing.T) {
+	g := New(Rules{Size: 3, K: 3, Variant: Ultimate}, X)
+
+	// X takes the top left sub-board with its top left cell, which sends O
+	// to a decided sub-board, so O may play anywhere.
+	play(t, g, 4, 36, 8, 72, 0)
+
+	u := g.(*UltimateBoard)
+
+	if u.Owner(0) != X || u.Active() != -1 {
+		t.Fatalf("owner %v, active %d", u.Owner(0), u.Active())
+	}
+
+	g.Undo()
+
+	if u.Owner(0) != None || u.Active() != 0 || g.Turn() != X {
+		t.Errorf("undo left owner %v, active %d, turn %v", u.Owner(0), u.Active(), g.Turn())
+	}
+
+	g.Redo()
+
+	if u.Owner(0) != X || u.Active() != -1 {
+		t.Errorf("redo left owner %v, active %d", u.Owner(0), u.Active())
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	g := New(Classic, X)
+
+	if err := Takeback(g, X); err != ErrNoUndo {
+		t.Errorf("takeback before moving: %v, want %v", err, ErrNoUndo)
+	}
+
+	play(t, g, 4, 0)
+
+	// X asks after O replied, so both moves go.
+	if err := Takeback(g, X); err != nil || len(g.History()) != 0 || g.Turn() != X {
+		t.Errorf("takeback: %v, history %v, turn %v", err, g.History(), g.Turn())
+	}
+
+	play(t, g, 4, 0)
+
+	if err := Takeback(g, O); err != nil || !reflect.DeepEqual(g.History(), []int{4}) ||
+		g.Turn() != O {
+		t.Errorf("takeback of the last move: %v, history %v", err, g.History())
+	}
+}
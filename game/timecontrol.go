@@ -0,0 +1,223 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl is how much thinking time each side gets in a round. Base is
+// the bank a side starts with and Increment is added to it after each of its
+// moves. PerMove, when set, also limits every single move. The zero value
+// is an untimed game.
+//
+// Written out it is the base and increment in seconds and the move limit,
+// any of them optional: "300" is five minutes sudden death, "180+2" three
+// minutes plus two seconds a move, "10/move" ten seconds for every move and
+// "none" no clock at all.
+type TimeControl struct {
+	Base, Increment, PerMove time.Duration
+}
+
+// MaxTime is the longest base, increment or move limit, so that each fits in
+// two bytes of seconds on the wire.
+const MaxTime = 65535 * time.Second
+
+var ErrTimeControl = errors.New("invalid time control")
+
+func (tc TimeControl) Timed() bool {
+	return tc.Base > 0 || tc.PerMove > 0
+}
+
+func (tc TimeControl) Validate() error {
+	for _, d := range []time.Duration{tc.Base, tc.Increment, tc.PerMove} {
+		if d < 0 || d > MaxTime || d%time.Second != 0 {
+			return fmt.Errorf("%w: times are whole seconds up to %d", ErrTimeControl,
+				MaxTime/time.Second)
+		}
+	}
+
+	if tc.Increment > 0 && tc.Base == 0 {
+		return fmt.Errorf("%w: an increment needs a base time", ErrTimeControl)
+	}
+
+	return nil
+}
+
+func (tc TimeControl) String() string {
+	if !tc.Timed() {
+		return "none"
+	}
+
+	parts := []string{}
+
+	if tc.Base > 0 {
+		part := strconv.Itoa(int(tc.Base / time.Second))
+
+		if tc.Increment > 0 {
+			part += "+" + strconv.Itoa(int(tc.Increment/time.Second))
+		}
+
+		parts = append(parts, part)
+	}
+
+	if tc.PerMove > 0 {
+		parts = append(parts, strconv.Itoa(int(tc.PerMove/time.Second))+"/move")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func ParseTimeControl(str string) (TimeControl, error) {
+	tc := TimeControl{}
+
+	if str == "" || str == "none" {
+		return tc, nil
+	}
+
+	seconds := func(s string) (time.Duration, error) {
+		n, err := strconv.Atoi(s)
+
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: %q is not a number of seconds", ErrTimeControl, s)
+		}
+
+		return time.Duration(n) * time.Second, nil
+	}
+
+	// The base and the move limit can each be given once.
+	seen := map[bool]bool{}
+
+	for _, field := range strings.Fields(str) {
+		var err error
+
+		limit := strings.HasSuffix(field, "/move")
+
+		if seen[limit] {
+			return TimeControl{}, fmt.Errorf("%w: %q sets a time twice", ErrTimeControl, str)
+		}
+
+		seen[limit] = true
+
+		if limit {
+			tc.PerMove, err = seconds(strings.TrimSuffix(field, "/move"))
+		} else if base, increment, ok := strings.Cut(field, "+"); ok {
+			if tc.Base, err = seconds(base); err == nil {
+				tc.Increment, err = seconds(increment)
+			}
+		} else {
+			tc.Base, err = seconds(field)
+		}
+
+		if err != nil {
+			return TimeControl{}, err
+		}
+	}
+
+	return tc, tc.Validate()
+}
+
+// Clocks keeps both sides' time under a TimeControl. At most one clock runs
+// at a time, the one of the side to move. Every method takes the current
+// time so that the clocks can be driven by anything.
+type Clocks struct {
+	control TimeControl
+	left    [2]time.Duration
+	running Side
+	since   time.Time
+}
+
+func NewClocks(control TimeControl) Clocks {
+	return Clocks{
+		control: control,
+		left:    [2]time.Duration{control.Base, control.Base},
+		running: None,
+	}
+}
+
+func (c *Clocks) Control() TimeControl {
+	return c.control
+}
+
+// Running returns the side whose clock is running, None if both are stopped.
+func (c *Clocks) Running() Side {
+	return c.running
+}
+
+// Start runs the clock of side, stopping the other one first.
+func (c *Clocks) Start(side Side, now time.Time) {
+	c.Stop(now)
+	c.running = side
+	c.since = now
+}
+
+// Stop charges the running side for the time since Start and gives it its
+// increment.
+func (c *Clocks) Stop(now time.Time) {
+	c.halt(now, c.control.Increment)
+}
+
+// Pause charges the running side for the time since Start without giving it
+// the increment, for when it stops without having moved.
+func (c *Clocks) Pause(now time.Time) {
+	c.halt(now, 0)
+}
+
+func (c *Clocks) halt(now time.Time, increment time.Duration) {
+	if c.running != X && c.running != O {
+		return
+	}
+
+	if c.control.Base > 0 {
+		c.left[c.running] += increment - now.Sub(c.since)
+	}
+
+	c.running = None
+}
+
+// Banks returns the time both sides have in the bank, without what the
+// running side has spent since Start.
+func (c *Clocks) Banks() [2]time.Duration {
+	return c.left
+}
+
+// Restore stops the clocks and puts back banks taken earlier with Banks.
+func (c *Clocks) Restore(banks [2]time.Duration) {
+	c.left = banks
+	c.running = None
+}
+
+// Left is how much time side has for the move it is on, or would be on.
+// Without a base time only the move limit counts, and a side that is not X or
+// O has none.
+func (c *Clocks) Left(side Side, now time.Time) time.Duration {
+	if side != X && side != O {
+		return 0
+	}
+
+	spent := time.Duration(0)
+
+	if side == c.running {
+		spent = now.Sub(c.since)
+	}
+
+	left := c.control.PerMove - spent
+
+	if bank := c.left[side] - spent; c.control.Base > 0 &&
+		(c.control.PerMove == 0 || bank < left) {
+		left = bank
+	}
+
+	if left < 0 {
+		return 0
+	}
+
+	return left
+}
+
+// Flagged reports whether side has run out of time.
+func (c *Clocks) Flagged(side Side, now time.Time) bool {
+	return c.control.Timed() && c.Left(side, now) <= 0
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		str  string
+		want TimeControl
+		err  bool
+	}{
+		{"", TimeControl{}, false},
+		{"none", TimeControl{}, false},
+		{"300", TimeControl{Base: 300 * time.Second}, false},
+		{"180+2", TimeControl{Base: 180 * time.Second, Increment: 2 * time.Second}, false},
+		{"10/move", TimeControl{PerMove: 10 * time.Second}, false},
+		{"60+1 5/move", TimeControl{60 * time.Second, time.Second, 5 * time.Second}, false},
+		{"+2", TimeControl{}, true},
+		{"0+2", TimeControl{}, true},
+		{"fast", TimeControl{}, true},
+		{"-5", TimeControl{}, true},
+		{"70000", TimeControl{}, true},
+		{"300 60", TimeControl{}, true},
+		{"180+2 60", TimeControl{}, true},
+		{"10/move 5/move", TimeControl{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseTimeControl(test.str)
+
+		if test.err {
+			if !errors.Is(err, ErrTimeControl) {
+				t.Errorf("ParseTimeControl(%q): got %v, want an error", test.str, err)
+			}
+
+			continue
+		}
+
+		if err != nil || got != test.want {
+			t.Errorf("ParseTimeControl(%q) = %v, %v, want %v", test.str, got, err, test.want)
+		}
+
+		if again, err := ParseTimeControl(got.String()); err != nil || again != got {
+			t.Errorf("%q does not parse back: %v, %v", got.String(), again, err)
+		}
+	}
+}
+
+func TestClocks(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(s float64) time.Time {
+		return start.Add(time.Duration(s * float64(time.Second)))
+	}
+
+	c := NewClocks(TimeControl{Base: 10 * time.Second, Increment: 2 * time.Second})
+
+	c.Start(X, at(0))
+
+	if left := c.Left(X, at(4)); left != 6*time.Second {
+		t.Errorf("X has %v after 4s, want 6s", left)
+	}
+
+	if left := c.Left(O, at(4)); left != 10*time.Second {
+		t.Errorf("O has %v while X thinks, want 10s", left)
+	}
+
+	c.Start(O, at(4))
+
+	if left := c.Left(X, at(100)); left != 8*time.Second {
+		t.Errorf("X has %v after its move, want 8s with the increment", left)
+	}
+
+	if !c.Flagged(O, at(14)) || c.Flagged(O, at(13.5)) {
+		t.Errorf("O should flag after exactly 10s")
+	}
+
+	c.Stop(at(5))
+
+	if c.Running() != None || c.Left(O, at(50)) != 11*time.Second {
+		t.Errorf("stopped clocks: running %v, O has %v", c.Running(), c.Left(O, at(50)))
+	}
+
+	if c.Left(None, at(50)) != 0 || c.Left(Draw, at(50)) != 0 {
+		t.Errorf("only X and O have time")
+	}
+}
+
+func TestClocksRestore(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewClocks(TimeControl{Base: 10 * time.Second, Increment: 2 * time.Second})
+
+	c.Start(X, now)
+	banks := c.Banks()
+
+	c.Start(O, now.Add(3*time.Second))
+	c.Restore(banks)
+
+	if c.Running() != None || c.Left(X, now) != 10*time.Second {
+		t.Errorf("restored clocks: running %v, X has %v, want 10s", c.Running(), c.Left(X, now))
+	}
+}
+
+func TestClocksPause(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewClocks(TimeControl{Base: 10 * time.Second, Increment: 2 * time.Second})
+
+	c.Start(X, now)
+	c.Pause(now.Add(3 * time.Second))
+	c.Start(X, now.Add(3*time.Second))
+
+	if left := c.Left(X, now.Add(3*time.Second)); left != 7*time.Second {
+		t.Errorf("X has %v after a pause, want 7s without an increment", left)
+	}
+}
+
+func TestClocksPerMove(t *testing.T) {
+	c := NewClocks(TimeControl{PerMove: 5 * time.Second})
+	now := time.Unix(0, 0)
+
+	c.Start(O, now)
+
+	if left := c.Left(O, now.Add(2*time.Second)); left != 3*time.Second {
+		t.Errorf("O has %v after 2s, want 3s", left)
+	}
+
+	c.Start(X, now.Add(4*time.Second))
+
+	if left := c.Left(O, now.Add(20*time.Second)); left != 5*time.Second {
+		t.Errorf("O has %v waiting, want a full move", left)
+	}
+
+	c = NewClocks(TimeControl{Base: 4 * time.Second, PerMove: 5 * time.Second})
+	c.Start(X, now)
+
+	if !c.Flagged(X, now.Add(4*time.Second)) {
+		t.Errorf("the base should run out before the move limit")
+	}
+
+	c = NewClocks(TimeControl{})
+	c.Start(X, now)
+
+	if c.Flagged(X, now.Add(time.Hour)) {
+		t.Errorf("an untimed game flagged")
+	}
+}
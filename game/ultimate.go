@@ -0,0 +1,243 @@
+package game
+
+// UltimateBoard is a 3x3 of classic boards. Cell i belongs to sub-board i/9
+// and is cell i%9 of it, both counted row by row. Playing cell c of any
+// sub-board sends the opponent to sub-board c, unless that one is already
+// decided, in which case the opponent may play anywhere.
+type UltimateBoard struct {
+	boards [9]Board
+	owners [9]Side
+	turn   Side
+	active int
+
+	history
+}
+
+func NewUltimateBoard(first Side) *UltimateBoard {
+	u := &UltimateBoard{turn: first, active: -1}
+
+	for i := range u.boards {
+		u.boards[i] = NewBoard(Classic, first)
+		u.owners[i] = None
+	}
+
+	return u
+}
+
+func (u *UltimateBoard) Clone() Game {
+	c := *u
+	c.history = u.history.share()
+
+	for i := range c.boards {
+		c.boards[i].cells = append([]Side(nil), u.boards[i].cells...)
+	}
+
+	return &c
+}
+
+func (u *UltimateBoard) Rules() Rules {
+	return Rules{Size: 3, K: 3, Variant: Ultimate}
+}
+
+func (u *UltimateBoard) Len() int {
+	return 81
+}
+
+func (u *UltimateBoard) Cell(i int) Side {
+	return u.boards[i/9].cells[i%9]
+}
+
+func (u *UltimateBoard) Cells() []Side {
+	cells := make([]Side, 0, 81)
+
+	for _, b := range u.boards {
+		cells = append(cells, b.cells...)
+	}
+
+	return cells
+}
+
+func (u *UltimateBoard) Turn() Side {
+	return u.turn
+}
+
+func (u *UltimateBoard) Active() int {
+	return u.active
+}
+
+// Owner returns who decided sub-board sub: a side, Draw, or None while it is
+// still open.
+func (u *UltimateBoard) Owner(sub int) Side {
+	return u.owners[sub]
+}
+
+// Playable reports whether the next move may go to sub-board sub.
+func (u *UltimateBoard) Playable(sub int) bool {
+	if u.Winner() != None || u.owners[sub] != None {
+		return false
+	}
+
+	return u.active == -1 || u.active == sub
+}
+
+func (u *UltimateBoard) Check(i int) error {
+	if i < 0 || i >= u.Len() {
+		return ErrOutOfRange
+	}
+
+	if u.Winner() != None {
+		return ErrGameOver
+	}
+
+	if u.Cell(i) != None {
+		return ErrOccupied
+	}
+
+	if !u.Playable(i / 9) {
+		return ErrWrongBoard
+	}
+
+	return nil
+}
+
+func (u *UltimateBoard) Legal(i int) bool {
+	return u.Check(i) == nil
+}
+
+func (u *UltimateBoard) Play(side Side, i int) error {
+	if err := u.Check(i); err != nil {
+		return err
+	}
+
+	if side != u.turn {
+		return ErrNotYourTurn
+	}
+
+	u.push(step{side, i, u.active})
+	u.place(side, i)
+
+	return nil
+}
+
+// Undo takes back the last move, reopening its sub-board if the move decided
+// it, and keeps it for Redo.
+func (u *UltimateBoard) Undo() error {
+	s, ok := u.undo()
+
+	if !ok {
+		return ErrNoUndo
+	}
+
+	u.set(None, s.cell)
+
+	u.turn = s.side
+	u.active = s.active
+
+	return nil
+}
+
+// Redo plays the last move taken back again.
+func (u *UltimateBoard) Redo() error {
+	s, ok := u.redo()
+
+	if !ok {
+		return ErrNoRedo
+	}
+
+	u.place(s.side, s.cell)
+
+	return nil
+}
+
+func (u *UltimateBoard) place(side Side, i int) {
+	u.set(side, i)
+
+	u.turn = side.Other()
+	u.active = i % 9
+
+	if u.owners[u.active] != None {
+		u.active = -1
+	}
+}
+
+// set marks cell i and resolves its sub-board, leaving turn and active alone.
+func (u *UltimateBoard) set(side Side, i int) {
+	sub := &u.boards[i/9]
+
+	sub.cells[i%9] = side
+	u.owners[i/9] = sub.Winner()
+}
+
+func (u *UltimateBoard) Moves() []int {
+	if u.Winner() != None {
+		return nil
+	}
+
+	moves := []int{}
+
+	for sub := range u.boards {
+		if !u.Playable(sub) {
+			continue
+		}
+
+		for c, v := range u.boards[sub].cells {
+			if v == None {
+				moves = append(moves, sub*9+c)
+			}
+		}
+	}
+
+	return moves
+}
+
+// meta returns the 3x3 board of sub-board owners, where drawn sub-boards
+// count for nobody.
+func (u *UltimateBoard) meta() Board {
+	b := NewBoard(Classic, u.turn)
+
+	for i, v := range u.owners {
+		if v == X || v == O {
+			b.cells[i] = v
+		}
+	}
+
+	return b
+}
+
+// Line returns the cells the winner holds in the three sub-boards that won
+// the game.
+func (u *UltimateBoard) Line() []int {
+	line := []int{}
+
+	meta := u.meta()
+
+	for _, sub := range meta.Line() {
+		for c, v := range u.boards[sub].cells {
+			if v == meta.cells[sub] {
+				line = append(line, sub*9+c)
+			}
+		}
+	}
+
+	if len(line) == 0 {
+		return nil
+	}
+
+	return line
+}
+
+func (u *UltimateBoard) Winner() Side {
+	meta := u.meta()
+
+	if line := meta.Line(); line != nil {
+		return meta.cells[line[0]]
+	}
+
+	for _, v := range u.owners {
+		if v == None {
+			return None
+		}
+	}
+
+	return Draw
+}
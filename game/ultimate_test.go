@@ -0,0 +1,138 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mark puts side on the given cells of sub-board sub without any checks.
+func mark(u *UltimateBoard, sub int, side Side, cells ...int) {
+	for _, c := range cells {
+		u.set(side, sub*9+c)
+	}
+}
+
+func TestUltimateSendsToBoard(t *testing.T) {
+	u := NewUltimateBoard(X)
+
+	if u.Active() != -1 || len(u.Moves()) != 81 {
+		t.Fatalf("opening: active %d, %d moves", u.Active(), len(u.Moves()))
+	}
+
+	// The middle cell of the top left board sends O to the middle board.
+	play(t, u, 4)
+
+	if u.Active() != 4 {
+		t.Errorf("active %d, want 4", u.Active())
+	}
+
+	if err := u.Play(O, 0); err != ErrWrongBoard {
+		t.Errorf("playing off the active board: %v, want %v", err, ErrWrongBoard)
+	}
+
+	for _, i := range u.Moves() {
+		if i/9 != 4 {
+			t.Fatalf("move %d is outside the active board", i)
+		}
+	}
+
+	play(t, u, 4*9+2)
+
+	if u.Active() != 2 {
+		t.Errorf("active %d, want 2", u.Active())
+	}
+}
+
+func TestUltimateFreeChoice(t *testing.T) {
+	tests := []struct {
+		name  string
+		owner Side
+		cells []int
+	}{
+		{"won", X, []int{0, 1, 2}},
+		{"drawn", Draw, []int{0, 2, 3, 7, 8}},
+	}
+
+	for _, test := range tests {
+		u := NewUltimateBoard(O)
+
+		if test.owner == Draw {
+			mark(u, 4, X, test.cells...)
+			mark(u, 4, O, 1, 4, 5, 6)
+		} else {
+			mark(u, 4, test.owner, test.cells...)
+		}
+
+		if u.Owner(4) != test.owner || u.Playable(4) {
+			t.Fatalf("%s: owner %v, playable %v", test.name, u.Owner(4), u.Playable(4))
+		}
+
+		// O plays the middle of the top left board, which would send X to
+		// the decided middle board.
+		play(t, u, 4)
+
+		if u.Active() != -1 {
+			t.Errorf("%s: active %d, want a free choice", test.name, u.Active())
+		}
+
+		if err := u.Check(4*9 + 0); err == nil {
+			t.Errorf("%s: a decided board took a move", test.name)
+		}
+
+		if !u.Legal(8*9 + 8) {
+			t.Errorf("%s: free choice refused another board", test.name)
+		}
+	}
+}
+
+func TestUltimateWinner(t *testing.T) {
+	u := NewUltimateBoard(X)
+
+	mark(u, 0, X, 0, 1, 2)
+	mark(u, 4, X, 0, 4, 8)
+	mark(u, 2, O, 2, 5, 8)
+
+	if u.Winner() != None || u.Line() != nil {
+		t.Fatalf("winner %v, line %v with two boards in a row", u.Winner(), u.Line())
+	}
+
+	mark(u, 8, X, 6, 7, 8)
+
+	if u.Winner() != X {
+		t.Errorf("winner %v, want X", u.Winner())
+	}
+
+	want := []int{0, 1, 2, 36, 40, 44, 78, 79, 80}
+
+	if line := u.Line(); !reflect.DeepEqual(line, want) {
+		t.Errorf("line %v, want %v", line, want)
+	}
+
+	if moves := u.Moves(); len(moves) != 0 {
+		t.Errorf("moves %v after the game ended", moves)
+	}
+}
+
+func TestUltimateDrawnBoards(t *testing.T) {
+	u := NewUltimateBoard(X)
+
+	// Drawn boards count for nobody, so X holding the top row around a
+	// drawn corner has no line.
+	mark(u, 0, X, 0, 1, 2)
+	mark(u, 1, X, 0, 1, 2)
+	mark(u, 2, X, 0, 2, 3, 7, 8)
+	mark(u, 2, O, 1, 4, 5, 6)
+
+	if u.Owner(2) != Draw || u.Winner() != None {
+		t.Fatalf("owner %v, winner %v", u.Owner(2), u.Winner())
+	}
+
+	// Deciding every other board without a line draws the game.
+	for sub, owner := range map[int]Side{3: O, 4: O, 5: X, 6: X, 7: X, 8: O} {
+		mark(u, sub, owner, 0, 1, 2)
+	}
+
+	if u.Winner() != Draw {
+		t.Errorf("winner %v with every board decided, want Draw", u.Winner())
+	}
+}
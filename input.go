@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Action is something the player asks for, whatever key, mouse button or
+// controller button it came from.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionPlaceMark
+	ActionUp
+	ActionDown
+	ActionLeft
+	ActionRight
+	ActionCell1
+	ActionCell2
+	ActionCell3
+	ActionCell4
+	ActionCell5
+	ActionCell6
+	ActionCell7
+	ActionCell8
+	ActionCell9
+	ActionToggleFullscreen
+	ActionToggleMouseCapture
+	ActionQuit
+	ActionUndo
+	ActionToggleDebug
+	ActionRedo
+	actionCount
+)
+
+var actionNames = [actionCount]string{
+	"none", "place_mark", "up", "down", "left", "right",
+	"cell_1", "cell_2", "cell_3", "cell_4", "cell_5", "cell_6", "cell_7",
+	"cell_8", "cell_9",
+	"toggle_fullscreen", "toggle_mouse_capture", "quit", "undo", "toggle_debug",
+	"redo",
+}
+
+func (a Action) String() string {
+	if a < 0 || a >= actionCount {
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+
+	return actionNames[a]
+}
+
+// Repeats reports whether holding a key down keeps firing the action.
+func (a Action) Repeats() bool {
+	return a >= ActionUp && a <= ActionRight
+}
+
+// Device is where a binding comes from.
+type Device uint8
+
+const (
+	DeviceKey Device = iota
+	DeviceMouse
+	DevicePad
+)
+
+var deviceNames = []string{"key", "mouse", "pad"}
+
+var mouseNames = map[int32]string{
+	sdl.BUTTON_LEFT:   "left",
+	sdl.BUTTON_MIDDLE: "middle",
+	sdl.BUTTON_RIGHT:  "right",
+}
+
+// Binding is one key, mouse button or controller button. In the config it
+// is written as key:Return, mouse:left or pad:a, using SDL's names for keys
+// and controller buttons.
+type Binding struct {
+	device Device
+	code   int32
+}
+
+func Key(code sdl.Keycode) Binding {
+	return Binding{DeviceKey, int32(code)}
+}
+
+func Mouse(button uint8) Binding {
+	return Binding{DeviceMouse, int32(button)}
+}
+
+func Pad(button uint8) Binding {
+	return Binding{DevicePad, int32(button)}
+}
+
+func (b Binding) String() string {
+	name := ""
+
+	switch b.device {
+	case DeviceKey:
+		name = sdl.GetKeyName(sdl.Keycode(b.code))
+	case DeviceMouse:
+		name = mouseNames[b.code]
+	case DevicePad:
+		name = sdl.GameControllerGetStringForButton(sdl.GameControllerButton(b.code))
+	}
+
+	if name == "" {
+		name = fmt.Sprint(b.code)
+	}
+
+	return deviceNames[b.device] + ":" + name
+}
+
+// ParseBinding reads what Binding.String writes, including the bare numbers
+// it falls back to for buttons SDL has no name for.
+func ParseBinding(str string) (Binding, error) {
+	device, name, ok := strings.Cut(strings.TrimSpace(str), ":")
+
+	if !ok {
+		return Binding{}, fmt.Errorf("%q is not device:name", str)
+	}
+
+	if code, err := strconv.ParseInt(name, 10, 32); err == nil {
+		for i := range deviceNames {
+			if deviceNames[i] == device {
+				return Binding{Device(i), int32(code)}, nil
+			}
+		}
+	}
+
+	switch device {
+	case "key":
+		if code := sdl.GetKeyFromName(name); code != sdl.K_UNKNOWN {
+			return Key(code), nil
+		}
+	case "mouse":
+		for code, n := range mouseNames {
+			if n == name {
+				return Binding{DeviceMouse, code}, nil
+			}
+		}
+	case "pad":
+		if code := sdl.GameControllerGetButtonFromString(name); code != sdl.CONTROLLER_BUTTON_INVALID {
+			return Binding{DevicePad, int32(code)}, nil
+		}
+	default:
+		return Binding{}, fmt.Errorf("%q is not key, mouse or pad", device)
+	}
+
+	return Binding{}, fmt.Errorf("unknown %s %q", device, name)
+}
+
+// EventBinding returns what was pressed in event, and whether it is a held
+// key repeating.
+func EventBinding(event sdl.Event) (Binding, bool, bool) {
+	switch t := event.(type) {
+	case *sdl.KeyboardEvent:
+		if t.Type == sdl.KEYDOWN {
+			return Key(t.Keysym.Sym), t.Repeat != 0, true
+		}
+	case *sdl.MouseButtonEvent:
+		if t.Type == sdl.MOUSEBUTTONDOWN {
+			return Mouse(t.Button), false, true
+		}
+	case *sdl.ControllerButtonEvent:
+		if t.Type == sdl.CONTROLLERBUTTONDOWN {
+			return Pad(t.Button), false, true
+		}
+	}
+
+	return Binding{}, false, false
+}
+
+// Bindings is the action map, every action with what triggers it.
+type Bindings [actionCount][]Binding
+
+func DefaultBindings() Bindings {
+	var b Bindings
+
+	b[ActionPlaceMark] = []Binding{Key(sdl.K_RETURN), Key(sdl.K_KP_ENTER),
+		Key(sdl.K_SPACE), Mouse(sdl.BUTTON_LEFT), Pad(sdl.CONTROLLER_BUTTON_A)}
+	b[ActionUp] = []Binding{Key(sdl.K_UP), Key(sdl.K_w),
+		Pad(sdl.CONTROLLER_BUTTON_DPAD_UP)}
+	b[ActionDown] = []Binding{Key(sdl.K_DOWN), Key(sdl.K_s),
+		Pad(sdl.CONTROLLER_BUTTON_DPAD_DOWN)}
+	b[ActionLeft] = []Binding{Key(sdl.K_LEFT), Key(sdl.K_a),
+		Pad(sdl.CONTROLLER_BUTTON_DPAD_LEFT)}
+	b[ActionRight] = []Binding{Key(sdl.K_RIGHT), Key(sdl.K_d),
+		Pad(sdl.CONTROLLER_BUTTON_DPAD_RIGHT)}
+
+	numpad := []sdl.Keycode{sdl.K_KP_1, sdl.K_KP_2, sdl.K_KP_3, sdl.K_KP_4,
+		sdl.K_KP_5, sdl.K_KP_6, sdl.K_KP_7, sdl.K_KP_8, sdl.K_KP_9}
+
+	for i, key := range numpad {
+		b[ActionCell1+Action(i)] = []Binding{Key(key)}
+	}
+
+	b[ActionToggleFullscreen] = []Binding{Key(sdl.K_F11)}
+	b[ActionToggleMouseCapture] = []Binding{Key(sdl.K_m)}
+	b[ActionQuit] = []Binding{Key(sdl.K_ESCAPE), Pad(sdl.CONTROLLER_BUTTON_BACK)}
+	b[ActionUndo] = []Binding{Key(sdl.K_BACKSPACE), Pad(sdl.CONTROLLER_BUTTON_B)}
+	b[ActionToggleDebug] = []Binding{Key(sdl.K_F3)}
+	b[ActionRedo] = []Binding{Key(sdl.K_y), Pad(sdl.CONTROLLER_BUTTON_Y)}
+
+	return b
+}
+
+// Action returns the action event triggers, ActionNone if it is not bound or
+// is a repeat of an action that does not repeat.
+func (b *Bindings) Action(event sdl.Event) Action {
+	pressed, repeat, ok := EventBinding(event)
+
+	if !ok {
+		return ActionNone
+	}
+
+	for action := range b {
+		for _, binding := range b[action] {
+			if binding == pressed && (!repeat || Action(action).Repeats()) {
+				return Action(action)
+			}
+		}
+	}
+
+	return ActionNone
+}
+
+// Bind makes binding trigger action. It replaces the bindings action had on
+// the same device and takes binding away from any other action.
+func (b *Bindings) Bind(action Action, binding Binding) {
+	for a := range b {
+		var kept []Binding
+
+		for _, old := range b[a] {
+			if old == binding || (Action(a) == action && old.device == binding.device) {
+				continue
+			}
+
+			kept = append(kept, old)
+		}
+
+		b[a] = kept
+	}
+
+	b[action] = append(b[action], binding)
+}
+
+// Format writes the bindings of action as the config does.
+func (b *Bindings) Format(action Action) string {
+	names := make([]string, len(b[action]))
+
+	for i, binding := range b[action] {
+		names[i] = binding.String()
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Parse replaces the bindings of action with a comma separated list. An
+// empty list unbinds it.
+func (b *Bindings) Parse(action Action, str string) error {
+	bindings := []Binding{}
+
+	for _, field := range strings.Split(str, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+
+		binding, err := ParseBinding(field)
+
+		if err != nil {
+			return err
+		}
+
+		bindings = append(bindings, binding)
+	}
+
+	b[action] = bindings
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func key(code sdl.Keycode, repeat uint8) sdl.Event {
+	return &sdl.KeyboardEvent{Type: sdl.KEYDOWN, Repeat: repeat, Keysym: sdl.Keysym{Sym: code}}
+}
+
+func TestBindingsAction(t *testing.T) {
+	b := DefaultBindings()
+
+	tests := []struct {
+		event sdl.Event
+		want  Action
+	}{
+		{key(sdl.K_a, 0), ActionLeft},
+		{key(sdl.K_a, 1), ActionLeft},
+		{key(sdl.K_RETURN, 0), ActionPlaceMark},
+		{key(sdl.K_RETURN, 1), ActionNone},
+		{key(sdl.K_KP_7, 0), ActionCell7},
+		{key(sdl.K_F11, 0), ActionToggleFullscreen},
+		{&sdl.KeyboardEvent{Type: sdl.KEYUP, Keysym: sdl.Keysym{Sym: sdl.K_RETURN}}, ActionNone},
+		{&sdl.MouseButtonEvent{Type: sdl.MOUSEBUTTONDOWN, Button: sdl.BUTTON_LEFT}, ActionPlaceMark},
+		{&sdl.MouseButtonEvent{Type: sdl.MOUSEBUTTONDOWN, Button: sdl.BUTTON_RIGHT}, ActionNone},
+		{&sdl.ControllerButtonEvent{Type: sdl.CONTROLLERBUTTONDOWN, Button: sdl.CONTROLLER_BUTTON_A}, ActionPlaceMark},
+		{&sdl.MouseMotionEvent{}, ActionNone},
+	}
+
+	for _, test := range tests {
+		if got := b.Action(test.event); got != test.want {
+			t.Errorf("%#v triggered %v, want %v", test.event, got, test.want)
+		}
+	}
+}
+
+func TestBindingsBind(t *testing.T) {
+	b := DefaultBindings()
+
+	b.Bind(ActionToggleFullscreen, Key(sdl.K_a))
+
+	if got := b.Action(key(sdl.K_a, 0)); got != ActionToggleFullscreen {
+		t.Errorf("a triggers %v after rebinding", got)
+	}
+
+	if got := b.Action(key(sdl.K_F11, 0)); got != ActionNone {
+		t.Errorf("F11 still triggers %v after rebinding", got)
+	}
+
+	if got := b.Action(key(sdl.K_LEFT, 0)); got != ActionLeft {
+		t.Errorf("left arrow triggers %v, the rebinding took more than a", got)
+	}
+
+	b.Bind(ActionUndo, Key(sdl.K_z))
+
+	pad := &sdl.ControllerButtonEvent{Type: sdl.CONTROLLERBUTTONDOWN, Button: sdl.CONTROLLER_BUTTON_B}
+
+	if got := b.Action(pad); got != ActionUndo {
+		t.Errorf("rebinding a key took the pad button of undo, got %v", got)
+	}
+}
+
+func TestParseBinding(t *testing.T) {
+	for _, str := range []string{"mouse:left", "key:13", "pad:0"} {
+		b, err := ParseBinding(str)
+
+		if err != nil {
+			t.Errorf("%s: %v", str, err)
+			continue
+		}
+
+		if again, _ := ParseBinding(b.String()); again != b {
+			t.Errorf("%s came back as %v", str, again)
+		}
+	}
+
+	for _, str := range []string{"left", "mouse:thumb", "joystick:1"} {
+		if _, err := ParseBinding(str); err == nil {
+			t.Errorf("%s parsed", str)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// lobby is what the server last told us about its rooms, and the error of
+// the last lobby request that failed.
+var lobby struct {
+	rooms   []protocol.Room
+	message string
+}
+
+// room is the join code of the room we are seated in, empty in the lobby.
+var room string
+
+// Request sends a lobby request without holding up the frame.
+func Request(m protocol.Message) {
+	enc := encoder
+
+	go func() {
+		if err := enc.Encode(m); err != nil {
+			fmt.Println("[CLIENT] Error sending:", err.Error())
+		}
+	}()
+}
+
+// The config room value that quick matches instead of joining a code.
+const roomQuick = "quick"
+
+// Seat asks for a seat the way the room setting says: a quick match, the
+// room with that code, or nothing to stay in the lobby.
+func Seat(code string) {
+	switch code {
+	case "":
+	case roomQuick:
+		Request(&protocol.QuickMatch{})
+	default:
+		Request(&protocol.JoinRoom{Code: code})
+	}
+}
+
+// RoomLabel describes a room in a line: its name, rules and time control.
+func RoomLabel(r protocol.Room) string {
+	rules := game.Rules{Size: int(r.Size), K: int(r.K), Variant: game.Variant(r.Variant)}
+	control := game.TimeControl{
+		Base:      time.Duration(r.Base) * time.Second,
+		Increment: time.Duration(r.Increment) * time.Second,
+		PerMove:   time.Duration(r.PerMove) * time.Second,
+	}
+
+	details := fmt.Sprintf("%dx%d", rules.Size, rules.Size)
+
+	if rules.Variant == game.Ultimate {
+		details = rules.Variant.String()
+	}
+
+	if control.Timed() {
+		details += " " + control.String()
+	}
+
+	name := r.Name
+
+	if width := lobbyLabelWidth - len(details) - 1; len(name) > width {
+		name = name[:clamp(width, 0, len(name))]
+	}
+
+	return name + " " + details
+}
+
+// The entries on the left of the lobby, the rooms are listed on the right.
+const (
+	lobbyQuick = iota
+	lobbyCreate
+	lobbyPrivate
+	lobbyCode
+	lobbyRefresh
+	lobbyActions
+)
+
+const (
+	lobbyTop, lobbyPitch = 36, 20
+	lobbyRooms           = 6
+	lobbyLabelWidth      = 22
+	lobbyCodeLength      = 6
+
+	// lobbyRefresh is how often the room list is asked for again.
+	lobbyRefreshEvery = 3 * time.Second
+)
+
+// LobbyScene lists the public rooms of the server next to buttons to quick
+// match, open a room, open a private one or type in a join code. Rooms with a
+// free seat are joined, full ones, listed in cyan, are watched. When there are
+// more rooms than slots, the slot after the last room says how many are left
+// out and turns the page. It gives way to the game as soon as the server
+// seats us.
+type LobbyScene struct {
+	buttons []Button
+	focus   Focus
+	listed  time.Time
+	page    int
+
+	// typing is set while a join code is being typed in.
+	typing bool
+	code   string
+}
+
+func (s *LobbyScene) Enter() {
+	s.buttons = nil
+	s.focus = Focus{-1}
+
+	for i := 0; i < lobbyActions; i++ {
+		pos := vec2{16, lobbyTop + float32(i)*lobbyPitch}
+		s.buttons = append(s.buttons, &SimpleButton{ButtonData: ButtonData{pos, vec2{96, 16}}})
+	}
+
+	for i := 0; i < lobbyRooms; i++ {
+		pos := vec2{128, lobbyTop + float32(i)*lobbyPitch}
+		s.buttons = append(s.buttons, &SimpleButton{ButtonData: ButtonData{pos, vec2{176, 16}}})
+	}
+
+	s.Refresh()
+}
+
+func (s *LobbyScene) Exit() {}
+
+func (s *LobbyScene) Refresh() {
+	s.listed = time.Now()
+	Request(&protocol.ListRooms{})
+}
+
+func (s *LobbyScene) Update() {
+	if room != "" {
+		scenes.Replace(&GameScene{})
+		return
+	}
+
+	if time.Since(s.listed) > lobbyRefreshEvery {
+		s.Refresh()
+	}
+}
+
+// Shown returns the rooms on the current page, and how many are left out of
+// it when the list does not fit, in which case the slot after them turns the
+// page.
+func (s *LobbyScene) Shown() ([]protocol.Room, int) {
+	if len(lobby.rooms) <= lobbyRooms {
+		s.page = 0
+		return lobby.rooms, 0
+	}
+
+	start := s.page * (lobbyRooms - 1)
+
+	if start >= len(lobby.rooms) {
+		s.page, start = 0, 0
+	}
+
+	end := start + lobbyRooms - 1
+
+	if end > len(lobby.rooms) {
+		end = len(lobby.rooms)
+	}
+
+	return lobby.rooms[start:end], len(lobby.rooms) - (end - start)
+}
+
+// Label returns the text of button i, empty for room slots with no room.
+func (s *LobbyScene) Label(i int) string {
+	switch i {
+	case lobbyQuick:
+		return "Quick match"
+	case lobbyCreate:
+		return "New room"
+	case lobbyPrivate:
+		return "New private"
+	case lobbyCode:
+		return "Join code"
+	case lobbyRefresh:
+		return "Refresh"
+	}
+
+	rooms, more := s.Shown()
+
+	switch i -= lobbyActions; {
+	case i >= 0 && i < len(rooms):
+		return RoomLabel(rooms[i])
+	case i == len(rooms) && more > 0:
+		return fmt.Sprintf("+%d more", more)
+	}
+
+	return ""
+}
+
+func (s *LobbyScene) Choose(i int) {
+	lobby.message = ""
+
+	switch i {
+	case lobbyQuick:
+		Seat(roomQuick)
+	case lobbyCreate:
+		Request(&protocol.CreateRoom{})
+	case lobbyPrivate:
+		Request(&protocol.CreateRoom{Private: true})
+	case lobbyCode:
+		s.typing, s.code = true, ""
+	case lobbyRefresh:
+		s.Refresh()
+	default:
+		rooms, more := s.Shown()
+
+		switch i -= lobbyActions; {
+		case i >= 0 && i < len(rooms):
+			if r := rooms[i]; r.Players >= 2 {
+				Request(&protocol.JoinRoom{Code: r.Code, Watch: true})
+			} else {
+				Seat(r.Code)
+			}
+		case i == len(rooms) && more > 0:
+			s.page++
+		}
+	}
+}
+
+func (s *LobbyScene) Draw() {
+	renderer.Bind(defaultTexture)
+
+	for i := range s.buttons {
+		if s.Label(i) != "" {
+			s.buttons[i].Draw()
+		}
+	}
+
+	renderer.Bind(fontTexture)
+
+	title := TextStyle{size: 16, color: vec4{0, 1, 0, 1}, align: AlignCenter}
+	DrawString(vec2{W / 2, 8}, "LOBBY", title)
+
+	center := defaultStyle
+	center.align = AlignCenter
+
+	for i := 0; i < lobbyActions; i++ {
+		DrawString(vec2{64, lobbyTop + float32(i)*lobbyPitch + 4}, s.Label(i), center)
+	}
+
+	rooms, more := s.Shown()
+
+	for i := range rooms {
+		style := defaultStyle
+
+		if rooms[i].Players >= 2 {
+			style.color = vec4{0, 1, 1, 1}
+		}
+
+		DrawString(vec2{132, lobbyTop + float32(i)*lobbyPitch + 4}, s.Label(lobbyActions+i),
+			style)
+	}
+
+	if more > 0 {
+		grey := defaultStyle
+		grey.color = vec4{.5, .5, .5, 1}
+
+		DrawString(vec2{132, lobbyTop + float32(len(rooms))*lobbyPitch + 4},
+			s.Label(lobbyActions+len(rooms)), grey)
+	}
+
+	if len(lobby.rooms) == 0 {
+		grey := center
+		grey.color = vec4{.5, .5, .5, 1}
+		DrawString(vec2{216, lobbyTop + 4}, "No rooms yet", grey)
+	}
+
+	if s.typing {
+		code := s.code + strings.Repeat("_", lobbyCodeLength-len(s.code))
+		DrawString(vec2{W / 2, H - 32}, "Code: "+code, center)
+	}
+
+	center.color = vec4{1, 0, 0, 1}
+	DrawTextBox(vec2{0, H - 16}, vec2{W, 16}, lobby.message, center)
+
+	renderer.Bind(defaultTexture)
+	player.Draw()
+}
+
+// Type takes the keys of a join code being typed in. Return joins, Escape
+// gives up.
+func (s *LobbyScene) Type(t *sdl.KeyboardEvent) {
+	if t.Type != sdl.KEYDOWN {
+		return
+	}
+
+	key := t.Keysym.Sym
+
+	switch {
+	case key == sdl.K_RETURN || key == sdl.K_KP_ENTER:
+		s.typing = false
+		Seat(s.code)
+	case key == sdl.K_ESCAPE:
+		s.typing = false
+	case key == sdl.K_BACKSPACE && len(s.code) > 0:
+		s.code = s.code[:len(s.code)-1]
+	case len(s.code) >= lobbyCodeLength:
+	case key >= sdl.K_a && key <= sdl.K_z:
+		s.code += string(rune('A' + int(key-sdl.K_a)))
+	case key >= sdl.K_0 && key <= sdl.K_9:
+		s.code += string(rune('0' + int(key-sdl.K_0)))
+	}
+}
+
+func (s *LobbyScene) Event(event sdl.Event, action Action) {
+	if s.typing {
+		if t, ok := event.(*sdl.KeyboardEvent); ok {
+			s.Type(t)
+		}
+
+		return
+	}
+
+	if _, ok := event.(*sdl.MouseMotionEvent); ok {
+		s.focus.Set(CheckButtonPress(player.pos, s.buttons), s.buttons)
+	}
+
+	// The focus moves within the column it is in, and between the columns
+	// on the same row.
+	rooms, more := s.Shown()
+	slots := len(rooms)
+
+	if more > 0 {
+		slots++
+	}
+
+	first, last := 0, lobbyActions-1
+
+	if s.focus.cell >= lobbyActions {
+		first, last = lobbyActions, lobbyActions+clamp(slots, 1, lobbyRooms)-1
+	}
+
+	switch action {
+	case ActionUp:
+		s.focus.Set(clamp(s.focus.cell-1, first, last), s.buttons)
+	case ActionDown:
+		s.focus.Set(clamp(s.focus.cell+1, first, last), s.buttons)
+	case ActionRight:
+		if s.focus.cell < lobbyActions && slots > 0 {
+			s.focus.Set(lobbyActions+clamp(s.focus.cell, 0, slots-1), s.buttons)
+		}
+	case ActionLeft:
+		if s.focus.cell >= lobbyActions {
+			s.focus.Set(clamp(s.focus.cell-lobbyActions, 0, lobbyActions-1), s.buttons)
+		}
+	case ActionPlaceMark:
+		if spot, ok := clicked(event); ok {
+			s.Choose(CheckButtonPress(spot, s.buttons))
+		} else {
+			s.Choose(s.focus.cell)
+		}
+	case ActionQuit:
+		ToMenu()
+	}
+}
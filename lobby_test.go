@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cardgame/protocol"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestRoomLabel(t *testing.T) {
+	tests := []struct {
+		room protocol.Room
+		want string
+	}{
+		{protocol.Room{Name: "maria's room", Size: 3, K: 3}, "maria's room 3x3"},
+		{protocol.Room{Name: "blitz", Size: 15, K: 5, Base: 180, Increment: 2}, "blitz 15x15 180+2"},
+		{protocol.Room{Name: "big", Size: 3, K: 3, Variant: 1}, "big ultimate"},
+		{protocol.Room{Name: "a rather long room name", Size: 3, K: 3, PerMove: 10},
+			"a rather l 3x3 10/move"},
+	}
+
+	for _, test := range tests {
+		if got := RoomLabel(test.room); got != test.want {
+			t.Errorf("RoomLabel(%+v) = %q, want %q", test.room, got, test.want)
+		}
+
+		if got := RoomLabel(test.room); len(got) > lobbyLabelWidth {
+			t.Errorf("%q is wider than %d", got, lobbyLabelWidth)
+		}
+	}
+}
+
+func TestLobbyScene(t *testing.T) {
+	record(t)
+
+	lobby.rooms = []protocol.Room{{Code: "K7QX2M", Name: "open", Size: 3, K: 3}}
+	defer func() { lobby.rooms, room = nil, "" }()
+
+	s := &LobbyScene{listed: time.Now()}
+	s.buttons = make([]Button, lobbyActions+lobbyRooms)
+
+	if s.Label(lobbyActions) != "open 3x3" || s.Label(lobbyActions+1) != "" {
+		t.Errorf("room slots show %q and %q", s.Label(lobbyActions), s.Label(lobbyActions+1))
+	}
+
+	s.Choose(lobbyCode)
+
+	press := func(key sdl.Keycode) {
+		s.Event(&sdl.KeyboardEvent{Type: sdl.KEYDOWN, Keysym: sdl.Keysym{Sym: key}}, ActionNone)
+	}
+
+	for _, key := range []sdl.Keycode{sdl.K_k, sdl.K_7, sdl.K_q, sdl.K_BACKSPACE, sdl.K_q,
+		sdl.K_x, sdl.K_2, sdl.K_m, sdl.K_z} {
+		press(key)
+	}
+
+	if !s.typing || s.code != "K7QX2M" {
+		t.Errorf("typed %q, want K7QX2M", s.code)
+	}
+
+	press(sdl.K_ESCAPE)
+
+	if s.typing {
+		t.Error("escape did not stop typing")
+	}
+
+	scenes = SceneStack{scenes: []Scene{s}}
+
+	room = "K7QX2M"
+	s.Update()
+
+	if _, ok := scenes.Top().(*GameScene); !ok {
+		t.Errorf("seated lobby shows %T, want *GameScene", scenes.Top())
+	}
+}
+
+func TestLobbyPages(t *testing.T) {
+	record(t)
+
+	lobby.rooms = nil
+	defer func() { lobby.rooms = nil }()
+
+	for i := 0; i < 12; i++ {
+		lobby.rooms = append(lobby.rooms, protocol.Room{Name: fmt.Sprint("room ", i), Size: 3})
+	}
+
+	s := &LobbyScene{listed: time.Now()}
+	s.buttons = make([]Button, lobbyActions+lobbyRooms)
+
+	more := lobbyActions + lobbyRooms - 1
+
+	if s.Label(lobbyActions) != "room 0 3x3" || s.Label(more) != "+7 more" {
+		t.Errorf("first page shows %q to %q", s.Label(lobbyActions), s.Label(more))
+	}
+
+	s.Choose(more)
+	s.Choose(more)
+
+	if s.Label(lobbyActions) != "room 10 3x3" || s.Label(lobbyActions+2) != "+10 more" {
+		t.Errorf("last page shows %q to %q", s.Label(lobbyActions), s.Label(lobbyActions+2))
+	}
+
+	s.Choose(lobbyActions + 2)
+
+	if s.Label(lobbyActions) != "room 0 3x3" {
+		t.Errorf("paging past the end shows %q, want the first page", s.Label(lobbyActions))
+	}
+
+	lobby.rooms = lobby.rooms[:lobbyRooms]
+
+	if s.Label(more) != "room 5 3x3" {
+		t.Errorf("a list that fits ends with %q", s.Label(more))
+	}
+}
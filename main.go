@@ -1,20 +1,28 @@
 package main
 
 import (
-	"bufio"
+	"cardgame/clock"
+	"cardgame/game"
+	"cardgame/protocol"
+	"cardgame/replay"
+	"flag"
 	"fmt"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/veandco/go-sdl2/img"
 	"github.com/veandco/go-sdl2/sdl"
+	"math"
 	"net"
 	"os"
 	"strconv"
-	"strings"
-	_ "time"
+	"time"
 )
 
 var ch1 chan []int8
+
+// The connection of the current game and its encoder. Only the main loop
+// uses them, Channel reads from a Session of its own.
 var connection net.Conn
+var encoder *protocol.Encoder
 
 type vec2 struct {
 	x, y float32
@@ -200,32 +208,14 @@ const (
 	serverPort     = "8080"
 )
 
-func ClientSend(i int, connection net.Conn) bool {
-	var err error
-
-	conv := strconv.Itoa(i)
-
-	_, err = connection.Write([]byte(conv))
+func ClientSend(i int, encoder *protocol.Encoder) bool {
+	err := encoder.Encode(&protocol.Move{Cell: uint16(i)})
 
 	if err != nil {
 		fmt.Println("[CLIENT] Error sending:", err.Error())
+		return false
 	}
 
-	// buffer := make([]byte, 1024)
-	// _, err = connection.Read(buffer)
-
-	// if err != nil {
-	// fmt.Println("[CLIENT] Error reading:", err.Error())
-	// }
-
-	// fmt.Println("is there an error?", string(buffer))
-
-	// if string(buffer)[0] == '1' {
-	// return true
-	// } else {
-	// return false
-	// }
-
 	return true
 }
 
@@ -279,76 +269,14 @@ func loadXPM(filepath string) Texture {
 	return Texture{w: surface.W, h: surface.H, id: id, surface: surface}
 }
 
-type Ticks struct {
-	frames, fps                                  uint32
-	previous, current, delta, frame, accumulator float32
-}
-
-var ticks Ticks
-
-const NONE = 0
+// sdlClock reads SDL's millisecond counter.
+type sdlClock struct{}
 
-const (
-	START        = 1
-	WAIT         = 2
-	DONE         = 4
-	JUST  uint32 = 8
-)
-
-const (
-	SIMPLE  = 1
-	TWO_WAY = 2
-	LOOP    = 4
-)
-
-type Timer struct {
-	state, config, delay, restartDelay, current uint32
-}
-
-func (t *Timer) Set(state uint32) {
-	t.state = state
-	t.current = sdl.GetTicks()
+func (sdlClock) Ticks() uint32 {
+	return sdl.GetTicks()
 }
 
-func (t *Timer) Update() {
-	diff := sdl.GetTicks() - t.current
-
-	if t.state == NONE || (t.state&DONE > 0 && t.config&LOOP > 0) {
-		t.Set(START | JUST)
-	} else if t.state&START > 0 && diff >= t.delay {
-		t.Set(DONE | JUST)
-	} else if t.state&JUST > 0 {
-		t.state &= ^JUST
-	}
-}
-
-func InitTicks() {
-	ticks = Ticks{
-		frames: 0, fps: 0,
-		previous: 0, delta: 0.01, frame: 0, accumulator: 0,
-		current: float32(sdl.GetTicks()) / 1000,
-	}
-}
-
-func (t Ticks) dt() float32 {
-	return t.delta
-}
-
-func (t *Ticks) Update() {
-	t.frames++
-
-	t.previous = t.current
-	t.current = float32(sdl.GetTicks()) / 1000
-	t.frame = t.current - t.previous
-
-	if t.frame > 0.25 {
-		t.frame = 0.25
-	}
-
-	t.accumulator += t.frame
-
-	t.fps = t.frames / (1 + sdl.GetTicks()/1000)
-}
+var ticks clock.Ticks
 
 func (s Shader) Location(str string) int32 {
 	return gl.GetUniformLocation(s.id, gl.Str(str+"\x00"))
@@ -369,8 +297,6 @@ func (s Shader) SetMat4(str string, m Mat4) {
 }
 
 func (s Shader) Use() {
-	gl.Flush()
-
 	gl.UseProgram(s.id)
 	gl.BindVertexArray(s.vao)
 	gl.BindBuffer(gl.ARRAY_BUFFER, s.vbo)
@@ -393,11 +319,13 @@ type Shader struct {
 	vao, vbo, ebo uint32
 }
 
-func LoadShaderFile(filepath string, shaderType uint32) uint32 {
+// LoadShaderFile compiles one shader stage, returning the compile log as the
+// error when it does not build.
+func LoadShaderFile(filepath string, shaderType uint32) (uint32, error) {
 	file, err := os.ReadFile(filepath)
 
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	id := gl.CreateShader(shaderType)
@@ -424,20 +352,30 @@ func LoadShaderFile(filepath string, shaderType uint32) uint32 {
 
 		gl.DeleteShader(id) // Don't leak the shader.
 
-		panic(string(errorLog))
+		return 0, fmt.Errorf("%s: %s", filepath, errorLog)
 	}
 
-	return id
+	return id, nil
 }
 
-func LoadShader(vertexFilePath string, fragmentFilePath string) Shader {
-	s := Shader{
-		gl.CreateProgram(),
-		LoadShaderFile(vertexFilePath, gl.VERTEX_SHADER),
-		LoadShaderFile(fragmentFilePath, gl.FRAGMENT_SHADER),
-		0, 0, 0,
+// LoadShader compiles and links a program from a vertex and a fragment
+// shader, returning the log as the error when either step fails.
+func LoadShader(vertexFilePath string, fragmentFilePath string) (Shader, error) {
+	vId, err := LoadShaderFile(vertexFilePath, gl.VERTEX_SHADER)
+
+	if err != nil {
+		return Shader{}, err
+	}
+
+	fId, err := LoadShaderFile(fragmentFilePath, gl.FRAGMENT_SHADER)
+
+	if err != nil {
+		gl.DeleteShader(vId)
+		return Shader{}, err
 	}
 
+	s := Shader{gl.CreateProgram(), vId, fId, 0, 0, 0}
+
 	gl.AttachShader(s.id, s.vId)
 	gl.AttachShader(s.id, s.fId)
 
@@ -450,21 +388,24 @@ func LoadShader(vertexFilePath string, fragmentFilePath string) Shader {
 	if isLinked == gl.FALSE {
 
 		maxLength := int32(0)
-		gl.GetShaderiv(s.id, gl.INFO_LOG_LENGTH, &maxLength)
+		gl.GetProgramiv(s.id, gl.INFO_LOG_LENGTH, &maxLength)
 
 		var errorLog []byte = make([]byte, int(maxLength))
-		gl.GetShaderInfoLog(s.id, maxLength, &maxLength, &errorLog[0])
+		gl.GetProgramInfoLog(s.id, maxLength, &maxLength, &errorLog[0])
 
-		gl.DeleteShader(s.id) // Don't leak the shader.
+		gl.DeleteProgram(s.id) // Don't leak the program or its shaders.
+		gl.DeleteShader(s.vId)
+		gl.DeleteShader(s.fId)
 
-		panic(string(errorLog))
+		return Shader{}, fmt.Errorf("linking %s and %s: %s", vertexFilePath,
+			fragmentFilePath, errorLog)
 	}
 
 	gl.GenVertexArrays(1, &s.vao)
 	gl.GenBuffers(1, &s.vbo)
 	gl.GenBuffers(1, &s.ebo)
 
-	return s
+	return s, nil
 }
 
 const (
@@ -475,7 +416,11 @@ const (
 )
 
 func DefaultShader() Shader {
-	s := LoadShader("vertex.glsl", "fragment.glsl")
+	s, err := LoadShader("vertex.glsl", "fragment.glsl")
+
+	if err != nil {
+		panic(err)
+	}
 
 	s.Use()
 
@@ -524,12 +469,17 @@ type Button interface {
 	Hover()
 	UnHover()
 	Set(sprite vec4, color vec4)
+	Place() Animation
 }
 
 type SimpleButton struct {
 	ButtonData
 	toggle, border bool
 	color, sprite  vec4
+
+	// shrink is how much smaller than the button its mark is drawn, it is
+	// animated down to 0 as the mark is placed.
+	shrink float32
 }
 
 func (b SimpleButton) IsClicked(spot vec2) bool {
@@ -557,9 +507,12 @@ type Player struct {
 
 var player Player
 
-func CheckWinCondition() {
+// board mirrors the last state received from the server and is used to reject
+// clicks on cells that can not be played before they reach the network.
+var board = game.New(game.Classic, game.X)
 
-}
+// rules is the board size, win length and variant of the game being played.
+var rules = game.Classic
 
 func (b *SimpleButton) Update() {
 	if !b.toggle {
@@ -576,26 +529,22 @@ func (t Texture) Coords(coords vec4) vec4 {
 
 func (b SimpleButton) Draw() {
 	if b.border {
-		defaultShader.SetMat4("uModel", getModel(b.pos.Sub(vec2{2, 2}),
-			b.size.Add(vec2{4, 4})))
-
-		defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{0, 16, 16, 16}))
-		defaultShader.SetVec4("uColor", vec4{.5, .5, 0, 1})
-
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		renderer.Draw(getModel(b.pos.Sub(vec2{2, 2}), b.size.Add(vec2{4, 4})),
+			defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{.5, .5, 0, 1})
 	}
 
-	defaultShader.SetMat4("uModel", getModel(b.pos, b.size))
+	renderer.Draw(getModel(b.pos, b.size), defaultTexture.Coords(vec4{0, 16, 16, 16}),
+		vec4{.2, .2, .2, 1})
 
-	defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{0, 16, 16, 16}))
-	defaultShader.SetVec4("uColor", vec4{.2, .2, .2, 1})
+	shrink := b.size.Mul(vec2{b.shrink, b.shrink})
 
-	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
-
-	defaultShader.SetVec4("uOffset", b.sprite)
-	defaultShader.SetVec4("uColor", b.color)
+	renderer.Draw(getModel(b.pos.Add(shrink.Div(vec2{2, 2})), b.size.Sub(shrink)),
+		b.sprite, b.color)
+}
 
-	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+// Place returns the animation of a mark popping into the button.
+func (b *SimpleButton) Place() Animation {
+	return TweenFloat(&b.shrink, 1, 0, markDuration, EaseOutBack, clock.SIMPLE)
 }
 
 func (b *SimpleButton) Set(sprite vec4, color vec4) {
@@ -611,19 +560,28 @@ type Engine struct {
 
 	buttons []Button
 
-	w, h         int32
-	realW, realH int32
+	w, h     int32
+	viewport Viewport
+
+	// debug shows the frame rate overlay.
+	debug bool
 }
 
-func (engine *Engine) Init() {
+func (engine *Engine) Init(config Config) {
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		panic(err)
 	}
 
-	var flags uint32 = sdl.WINDOW_SHOWN | sdl.WINDOW_OPENGL
-	// | sdl.WINDOW_FULLSCREEN_DESKTOP
+	var flags uint32 = sdl.WINDOW_SHOWN | sdl.WINDOW_OPENGL |
+		sdl.WINDOW_ALLOW_HIGHDPI
 
-	window, err := sdl.CreateWindow("gocard", 0, 0, W, H, flags)
+	if config.fullscreen {
+		flags |= sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+
+	scale := int32(config.scale)
+
+	window, err := sdl.CreateWindow("gocard", 0, 0, W*scale, H*scale, flags)
 
 	if err != nil {
 		panic(err)
@@ -645,16 +603,25 @@ func (engine *Engine) Init() {
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
-	*engine = Engine{true, 0, 0, window, context, []Button{}, W, H, W, H}
+	*engine = Engine{true, 0, 0, window, context, []Button{}, W, H,
+		Viewport{scaling: config.scaling}, false}
+
+	engine.Resize()
 }
 
-func CheckButtonPress(spot vec2, buttons []Button) int {
-	factor := vec2{
-		float32(engine.realW / engine.w),
-		float32(engine.realH / engine.h),
-	}
+// Resize fits the viewport to the window again after it changed size.
+func (engine *Engine) Resize() {
+	windowW, windowH := engine.window.GetSize()
+	drawableW, drawableH := engine.window.GLGetDrawableSize()
 
-	spot = spot.Div(factor)
+	engine.viewport.Resize(windowW, windowH, drawableW, drawableH)
+	engine.viewport.Apply()
+}
+
+// CheckButtonPress returns the button under spot, spot being in window
+// coordinates, or -1.
+func CheckButtonPress(spot vec2, buttons []Button) int {
+	spot = engine.viewport.ToWorld(spot)
 
 	for i := range buttons {
 		if buttons[i].IsClicked(spot) {
@@ -666,42 +633,99 @@ func CheckButtonPress(spot vec2, buttons []Button) int {
 	return -1
 }
 
+// simulation is the clock of the fixed step simulation. It only moves when a
+// step is taken, so timers on it pause along with the simulation.
+var simulation clock.Fake
+
+var timer clock.Timer
+
+// Physics runs the simulation in fixed steps of ticks.DT(), as many as the
+// time since the last frame makes up for, and leaves the animations showing
+// where they are between the last two steps.
 func (engine *Engine) Physics() {
-	for ticks.accumulator >= ticks.dt() {
-		// we gotta do something
-		ticks.accumulator -= ticks.dt()
+	for ticks.Step() {
+		Simulate(ticks.DT())
 	}
+
+	animations.Apply(ticks.Alpha())
 }
 
+// Simulate moves everything that runs on game time one step of dt seconds.
+func Simulate(dt float32) {
+	simulation.Advance(uint32(dt*1000 + .5))
+
+	timer.Update()
+	animations.Update(dt)
+}
+
+// PlaceMark sends a click on cell i to whoever owns the board. Clicks out of
+// turn are ignored and cells the local copy knows can not be played flash
+// without reaching the network.
+func PlaceMark(i int) {
+	if i < 0 || !MyTurn() {
+		return
+	}
+
+	if !board.Legal(i) {
+		Reject()
+		return
+	}
+
+	if offline {
+		LocalSend(i)
+	} else {
+		go ClientSend(i, encoder)
+	}
+}
+
+// Turn is the side to move, None once the round is decided.
+func Turn() game.Side {
+	if RoundWinner() != game.None {
+		return game.None
+	}
+
+	return board.Turn()
+}
+
+// MyTurn reports whether the board takes our moves right now.
+func MyTurn() bool {
+	return ready && Turn() == game.Side(side)
+}
+
+// How long the red flash of a refused move lasts, and how strong it starts.
+const flashDuration, flashAlpha = .3, .35
+
+// flash is the alpha of the red tint over the board, animated by Reject.
+var flash float32
+var flashTween *Tween
+
+// Reject flashes the board red, for a move that was refused.
+func Reject() {
+	if flashTween != nil {
+		animations.Remove(flashTween)
+	}
+
+	flashTween = TweenFloat(&flash, flashAlpha, 0, flashDuration, EaseOutQuad, clock.SIMPLE)
+	animations.Add(flashTween)
+}
+
+// Event handles what every screen shares, the window, the cursor and the
+// global actions, and hands each event to the current scene along with the
+// action it is bound to.
 func (engine *Engine) Event() {
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 		switch t := event.(type) {
 		case *sdl.WindowEvent:
 			switch t.Event {
-			case sdl.WINDOWEVENT_RESIZED:
-				engine.realW, engine.realH = t.Data1, t.Data2
-				gl.Viewport(0, 0, t.Data1, t.Data2)
+			case sdl.WINDOWEVENT_RESIZED, sdl.WINDOWEVENT_SIZE_CHANGED:
+				engine.Resize()
 			}
 			break
 		case *sdl.QuitEvent:
 			engine.run = false
 			break
-		case *sdl.MouseButtonEvent:
-			switch t.Type {
-			case sdl.MOUSEBUTTONDOWN:
-				switch t.Button {
-				case sdl.BUTTON_LEFT:
-					result := CheckButtonPress(vec2{float32(t.X), float32(t.Y)},
-						engine.buttons)
-
-					if result > -1 {
-						go ClientSend(result, connection)
-					}
-
-					break
-				}
-				break
-			}
+		case *sdl.ControllerDeviceEvent:
+			ControllerEvent(t)
 			break
 		case *sdl.MouseMotionEvent:
 			player.pos = vec2{
@@ -714,62 +738,59 @@ func (engine *Engine) Event() {
 			// 	float32(t.YRel),
 			// })
 
-			spot := player.pos
-
-			factor := vec2{
-				float32(engine.realW / engine.w),
-				float32(engine.realH / engine.h),
-			}
-
-			spot = spot.Div(factor)
+			break
+		}
 
-			for i := range engine.buttons {
-				if engine.buttons[i].IsClicked(spot) {
-					engine.buttons[i].Hover()
-				} else {
-					engine.buttons[i].UnHover()
-				}
-			}
+		action := config.bindings.Action(event)
 
-			break
-		case *sdl.KeyboardEvent:
-			if t.Repeat == 0 && t.Type == sdl.KEYDOWN {
-				switch t.Keysym.Sym {
-				case sdl.K_a:
-					if engine.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP > 0 {
-						engine.window.SetFullscreen(0)
-					} else {
-						engine.window.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
-					}
-
-					break
-				case sdl.K_s:
-					if sdl.GetRelativeMouseMode() {
-						sdl.SetRelativeMouseMode(false)
-					} else {
-						sdl.SetRelativeMouseMode(true)
-					}
-					break
-				}
+		switch action {
+		case ActionToggleFullscreen:
+			if engine.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP > 0 {
+				engine.window.SetFullscreen(0)
+			} else {
+				engine.window.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
 			}
-
-			break
+		case ActionToggleMouseCapture:
+			sdl.SetRelativeMouseMode(!sdl.GetRelativeMouseMode())
+		case ActionToggleDebug:
+			engine.debug = !engine.debug
 		}
+
+		scenes.Event(event, action)
 	}
 }
 
+// GridSquareSize returns the biggest whole pixel square, up to 16, that lets
+// a k by l grid fit the logical resolution below the score row.
+func GridSquareSize(k float32, l float32) float32 {
+	size := float32(math.Min(
+		float64(float32(engine.w)/(k*1.5)),
+		float64(float32(engine.h-32)/(l*1.5)),
+	))
+
+	return float32(math.Min(16, math.Floor(float64(size))))
+}
+
 func AddGridButtons(k float32, l float32, squareSize float32) []Button {
+	center := vec2{float32(engine.w) / 2, float32(engine.h) / 2}
+
+	return AddGridButtonsAt(center, k, l, squareSize)
+}
+
+// AddGridButtonsAt lays out a k by l grid centered on center.
+func AddGridButtonsAt(center vec2, k float32, l float32, squareSize float32) []Button {
 	var buttons []Button
 
 	size := vec2{squareSize, squareSize}
 
 	middle := vec2{
-		float32(engine.w)/2 - ((k * (size.x * 1.5)) / 2),
-		float32(engine.h)/2 - ((l * (size.y * 1.5)) / 2),
+		center.x - ((k * (size.x * 1.5)) / 2),
+		center.y - ((l * (size.y * 1.5)) / 2),
 	}
 
-	for i := float32(0); i < k; i++ {
-		for j := float32(0); j < l; j++ {
+	// Row major, like game.Board: button n sits in column n%k and row n/k.
+	for j := float32(0); j < l; j++ {
+		for i := float32(0); i < k; i++ {
 			buttons = append(buttons, &SimpleButton{
 				ButtonData: ButtonData{
 					pos:  middle.Add(vec2{i * (size.x * 1.5), j * (size.y * 1.5)}),
@@ -785,275 +806,916 @@ func AddGridButtons(k float32, l float32, squareSize float32) []Button {
 	return buttons
 }
 
-func Norm() vec2 {
-	return vec2{
-		float32(engine.realW / engine.w),
-		float32(engine.realH / engine.h),
+// SubBoard frames one of the nine mini-grids of an Ultimate board and lights
+// up with the hover border while moves there are legal.
+type SubBoard struct {
+	ButtonData
+	active bool
+}
+
+var subBoards []SubBoard
+
+func (s SubBoard) Draw() {
+	if !s.active {
+		return
 	}
+
+	renderer.Draw(getModel(s.pos.Sub(vec2{2, 2}), s.size.Add(vec2{4, 4})),
+		defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{.5, .5, 0, 1})
 }
 
-func (p Player) Draw() {
-	pos := p.pos.Sub(vec2{4, 4}.Mul(Norm()))
+// AddUltimateButtons lays out nine 3x3 grids, one per sub-board, so that
+// button i is cell i%9 of sub-board i/9. It also sets up subBoards.
+func AddUltimateButtons() []Button {
+	var buttons []Button
+
+	squareSize := GridSquareSize(10, 10)
+	pitch := squareSize * 5
 
-	defaultShader.SetMat4("uModel", getModel(pos.Div(Norm()),
-		vec2{8, 8}))
+	subBoards = nil
 
-	defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{32, 0, 16, 16}))
-	defaultShader.SetVec4("uColor", p.color)
+	for sub := float32(0); sub < 9; sub++ {
+		x, y := float32(int(sub)%3), float32(int(sub)/3)
+
+		center := vec2{
+			float32(engine.w)/2 + (x-1)*pitch,
+			float32(engine.h)/2 + (y-1)*pitch,
+		}
+
+		grid := AddGridButtonsAt(center, 3, 3, squareSize)
+		first := grid[0].(*SimpleButton)
+		last := grid[len(grid)-1].(*SimpleButton)
+
+		subBoards = append(subBoards, SubBoard{
+			ButtonData: ButtonData{
+				pos:  first.pos,
+				size: last.pos.Add(last.size).Sub(first.pos),
+			},
+		})
+
+		buttons = append(buttons, grid...)
+	}
 
-	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	return buttons
+}
+
+// Draw draws the cursor centered on the mouse, p.pos being in window
+// coordinates.
+func (p Player) Draw() {
+	pos := engine.viewport.ToWorld(p.pos).Sub(vec2{4, 4})
+
+	renderer.Draw(getModel(pos, vec2{8, 8}),
+		defaultTexture.Coords(vec4{32, 0, 16, 16}), p.color)
 }
 
 var ready bool = false
+var reconnecting bool = false
+
+// token is the session token the server issued at join. Presenting it again
+// after a dropped connection gets the same seat, board and scores back.
+var token string
+
+// Backoff bounds for Reconnect.
+const (
+	reconnectMin = 250 * time.Millisecond
+	reconnectMax = 8 * time.Second
+)
+
+// Session is a connection to the server that went through the handshake.
+type Session struct {
+	conn net.Conn
+	enc  *protocol.Encoder
+	dec  *protocol.Decoder
+}
+
+// Connect dials the server and says hello, presenting the token resume to
+// get that seat back if it is not empty. It returns the new session and the
+// side assignment the server answers a token with, nil without one. It
+// leaves the current connection alone, that is for the main loop to swap.
+func Connect(config Connection, resume string) (*Session, *protocol.AssignSide, error) {
+	conn, err := net.Dial(config.protocol, config.host+":"+config.port)
 
-func Channel() {
-	buffer := make([]byte, 1024)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc, dec := protocol.NewEncoder(conn), protocol.NewDecoder(conn)
+
+	hello := &protocol.Hello{
+		Name:    config.name,
+		Token:   resume,
+		Size:    uint8(config.size),
+		K:       uint8(config.k),
+		Variant: uint8(config.variant),
+
+		Base:      uint16(config.control.Base / time.Second),
+		Increment: uint16(config.control.Increment / time.Second),
+		PerMove:   uint16(config.control.PerMove / time.Second),
+	}
 
-	connection.Read(buffer)
+	if err = enc.Encode(hello); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	s := &Session{conn, enc, dec}
+
+	if resume == "" {
+		return s, nil, nil
+	}
 
-	ready = true
+	msg, err := dec.Decode()
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	switch msg := msg.(type) {
+	case *protocol.AssignSide:
+		return s, msg, nil
+	case *protocol.Error:
+		conn.Close()
+		return nil, nil, msg
+	default:
+		conn.Close()
+		return nil, nil, fmt.Errorf("expected side assignment, got %T", msg)
+	}
+}
+
+// An event is what Channel or LocalChannel hand over to the main loop: a
+// message, or a change of the connection when there is none. A connection
+// that is up again comes with its session.
+type event struct {
+	msg     protocol.Message
+	link    link
+	session *Session
+}
+
+// link tells the main loop what happened to the connection.
+type link uint8
+
+const (
+	linkSame   link = iota
+	linkDown        // lost, Reconnect is trying again
+	linkForgot      // the server no longer knows our seat
+	linkUp          // through again
+)
+
+// inbox carries the events of the current game to the main loop, which is
+// the only one to apply them. Every game gets its own, so that nothing sent
+// before Leave is applied after it.
+var inbox chan event
+
+// inboxSize is how many events can wait for the next frame.
+const inboxSize = 64
+
+// post hands e over to the main loop and reports false if done was closed
+// meanwhile.
+func post(inbox chan<- event, e event, done chan struct{}) bool {
+	select {
+	case inbox <- e:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Reconnect retries Connect with exponential backoff until it succeeds, and
+// returns the new session, or until the player leaves, in which case it
+// returns nil and a session that came too late is closed. While it runs the
+// board is hidden behind the waiting square. If the server no longer knows
+// seat the next attempt goes back to the lobby instead.
+func Reconnect(config Connection, seat *protocol.AssignSide, inbox chan<- event,
+	done chan struct{}) *Session {
+	if !post(inbox, event{link: linkDown}, done) {
+		return nil
+	}
+
+	delay := reconnectMin
+
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		s, assign, err := Connect(config, seat.Token)
+
+		if err == nil {
+			if (assign != nil && !post(inbox, event{msg: assign}, done)) ||
+				!post(inbox, event{link: linkUp, session: s}, done) {
+				s.conn.Close()
+				return nil
+			}
+
+			return s
+		}
+
+		if _, ok := err.(*protocol.Error); ok {
+			fmt.Println("[CLIENT] Could not resume, back to the lobby:", err.Error())
+			*seat = protocol.AssignSide{}
+
+			if !post(inbox, event{link: linkForgot}, done) {
+				return nil
+			}
+
+			continue
+		}
+
+		fmt.Println("[CLIENT] Error reconnecting, retrying in", delay, err.Error())
+
+		if !wait(delay, done) {
+			return nil
+		}
+
+		if delay *= 2; delay > reconnectMax {
+			delay = reconnectMax
+		}
+	}
+}
+
+// Channel passes what the server sends over s on to the main loop until done
+// is closed, and reconnects when the connection drops. It keeps its own copy
+// of the seat it was given, to claim it back with.
+func Channel(config Connection, s *Session, inbox chan<- event, done chan struct{}) {
+	seat := protocol.AssignSide{}
 
 	for {
-		_, err := connection.Read(buffer)
+		msg, err := s.dec.Decode()
+
+		select {
+		case <-done:
+			return
+		default:
+		}
 
 		if err != nil {
-			panic("wtf mate?")
+			if protocol.Malformed(err) {
+				fmt.Println("[CLIENT] Error reading:", err.Error())
+				continue
+			}
+
+			fmt.Println("[CLIENT] Connection lost:", err.Error())
+
+			s.conn.Close()
+
+			if s = Reconnect(config, &seat, inbox, done); s == nil {
+				return
+			}
+
+			continue
 		}
 
-		result := strings.Split(string(buffer), ",")
+		if assign, ok := msg.(*protocol.AssignSide); ok {
+			seat = *assign
+		}
 
-		score1, _ := strconv.Atoi(result[0])
-		score2, _ := strconv.Atoi(result[1])
-		winner, _ := strconv.Atoi(result[11])
+		if !post(inbox, event{msg: msg}, done) {
+			return
+		}
+	}
+}
 
-		engine.score1 = uint8(score1)
-		engine.score2 = uint8(score2)
+// Dispatch applies the events that arrived since the last frame.
+func Dispatch() {
+	for {
+		select {
+		case e := <-inbox:
+			Receive(e)
+		default:
+			return
+		}
+	}
+}
 
-		if winner > -1 {
+// Receive applies an event from Channel or LocalChannel. It runs on the main
+// goroutine, which owns the board, the scenes and the rest of the game state.
+// Spectators have no session to resume and simply ask to watch the same room
+// again once the connection is back.
+func Receive(e event) {
+	switch e.link {
+	case linkDown:
+		ready, reconnecting = false, true
+	case linkForgot:
+		token, room = "", ""
+	case linkUp:
+		connection, encoder = e.session.conn, e.session.enc
+		reconnecting = false
+
+		if token == "" && room != "" {
+			Request(&protocol.JoinRoom{Code: room, Watch: true})
+		}
+	}
 
+	switch msg := e.msg.(type) {
+	case *protocol.AssignSide:
+		SetSide(game.Side(msg.Side))
+		token, room = msg.Token, msg.Room
+	case *protocol.Rooms:
+		lobby.rooms = msg.Rooms
+	case *protocol.Roster:
+		roster = *msg
+		tracker.Names = msg.Names
+	case *protocol.Ready:
+		SetRules(game.Rules{
+			Size:    int(msg.Size),
+			K:       int(msg.K),
+			Variant: game.Variant(msg.Variant),
+		})
+		turnClocks = NewTurnClocks(game.TimeControl{
+			Base:      time.Duration(msg.Base) * time.Second,
+			Increment: time.Duration(msg.Increment) * time.Second,
+			PerMove:   time.Duration(msg.PerMove) * time.Second,
+		})
+		tracker.Rules, tracker.Control = rules, turnClocks.control
+		ready = true
+	case *protocol.State:
+		cells := make([]game.Side, len(msg.Cells))
+
+		for i, v := range msg.Cells {
+			cells[i] = game.Side(v)
 		}
 
-		for i, index := 0, 2; index < 11; i, index = i+1, index+1 {
-			value, err := strconv.Atoi(result[index])
+		ApplyState(msg.Score[0], msg.Score[1], cells, game.Side(msg.Turn),
+			int(msg.Active))
 
-			if err != nil {
-				panic(err)
-			} else {
-				if value == 0 {
-					engine.buttons[i].Set(defaultTexture.Coords(vec4{0, 0, 16, 16}),
-						vec4{0, 1, 0, 1})
-				} else if value == 1 {
-					engine.buttons[i].Set(defaultTexture.Coords(vec4{16, 0, 16, 16}), vec4{1, 0, 0, 1})
-				} else {
-					engine.buttons[i].Set(vec4{0, 0, 0, 0}, vec4{0, 0, 1, 1})
-				}
+		offered = false
+
+		flagged := game.None
+
+		if msg.Reason == protocol.ReasonTime {
+			flagged = game.Side(msg.Winner).Other()
+		}
+
+		turnClocks.Sync(msg.Clock, game.Side(msg.Turn), game.Side(msg.Winner), flagged,
+			time.Now())
+
+		Track(RoundWinner(), flagged != game.None)
+	case *protocol.Takeback:
+		offered = true
+	case *protocol.TakebackReply:
+		if msg.Accept {
+			Notify("Takeback accepted")
+		} else {
+			Notify("Takeback declined")
+		}
+	case *protocol.GameOver:
+		if msg.Reason == protocol.ReasonTime {
+			fmt.Println("[CLIENT] Round over on time, winner:", msg.Winner)
+		} else {
+			fmt.Println("[CLIENT] Round over, winner:", msg.Winner)
+		}
+	case *protocol.Error:
+		fmt.Println("[CLIENT] Server error:", msg.Text)
+
+		if room == "" {
+			lobby.message = msg.Text
+		} else {
+			Reject()
+		}
+	}
+}
+
+// done is closed when the player leaves the current game, telling Channel,
+// Reconnect or LocalChannel to stop.
+var done chan struct{}
+
+// StartOnline enters the lobby of the configured server, and asks for the
+// configured room right away if there is one.
+func StartOnline() error {
+	token, room = "", ""
+	lobby.rooms, lobby.message = nil, ""
+	tracker = replay.Tracker{}
+
+	s, _, err := Connect(config.Connection, "")
+
+	if err != nil {
+		return err
+	}
+
+	connection, encoder = s.conn, s.enc
+	offline = false
+	done = make(chan struct{})
+	inbox = make(chan event, inboxSize)
+
+	go Channel(config.Connection, s, inbox, done)
+
+	Seat(config.room)
+
+	return nil
+}
+
+// StartOffline starts a game against the AI with the configured rules and
+// difficulty. The human always plays X.
+func StartOffline() error {
+	difficulty, err := game.ParseDifficulty(config.difficulty)
+
+	if err != nil {
+		return err
+	}
+
+	local := config.Rules()
+
+	if err = local.Validate(); err != nil {
+		return err
+	}
+
+	offline = true
+	done = make(chan struct{})
+	inbox = make(chan event, inboxSize)
+
+	SetSide(game.X)
+
+	tracker = replay.Tracker{}
+	tracker.Names[game.X] = config.name
+	tracker.Names[game.O] = "AI (" + difficulty.String() + ")"
+
+	if tracker.Names[game.X] == "" {
+		tracker.Names[game.X] = "You"
+	}
+
+	go LocalChannel(local, game.X, difficulty, inbox, done)
+
+	return nil
+}
+
+// Leave ends the current game, online or not, and forgets its scores.
+func Leave() {
+	if done != nil {
+		close(done)
+		done = nil
+	}
+
+	if connection != nil {
+		connection.Close()
+		connection, encoder = nil, nil
+	}
+
+	inbox = nil
+	ready, reconnecting, offline, offered = false, false, false, false
+	token, room = "", ""
+	roster = protocol.Roster{}
+	notice.text = ""
+	engine.score1, engine.score2 = 0, 0
+	turnClocks = NewTurnClocks(game.TimeControl{})
+}
+
+// How long a placed mark takes to pop in, and how long each cell of the
+// winning line takes to light up, in seconds.
+const markDuration, sweepDuration = .25, .08
+
+// ApplyState updates the scores, the local board and the grid buttons from a
+// full snapshot, whether it came from the server or from LocalChannel, turn
+// being the side to move. When the snapshot is one move on from the last one
+// the new mark pops in and a winning line sweeps, bigger jumps such as joining
+// or resuming a game are shown as they are.
+func ApplyState(score1 uint8, score2 uint8, cells []game.Side, turn game.Side, active int) {
+	if len(cells) != len(engine.buttons) {
+		fmt.Println("[CLIENT] State has", len(cells), "cells, board has",
+			len(engine.buttons))
+		return
+	}
+
+	engine.score1 = score1
+	engine.score2 = score2
+
+	previous := board
+	board = game.Restore(rules, cells, turn, active)
+
+	placed := []int{}
+
+	if previous.Len() == len(cells) {
+		for i, v := range cells {
+			if v != game.None && previous.Cell(i) == game.None {
+				placed = append(placed, i)
 			}
 		}
 	}
+
+	moved := len(placed) == 1
+
+	if u, ok := board.(*game.UltimateBoard); ok {
+		for i := range subBoards {
+			subBoards[i].active = u.Playable(i)
+		}
+	}
+
+	for i, v := range cells {
+		sprite, color := SideSprite(v)
+		engine.buttons[i].Set(sprite, color)
+	}
+
+	if moved {
+		animations.Add(engine.buttons[placed[0]].Place())
+	}
+
+	sweep := NewSequence()
+
+	for _, i := range board.Line() {
+		button := engine.buttons[i]
+		sprite, color := SideSprite(board.Cell(i))
+
+		if !moved {
+			button.Set(sprite, vec4{1, 1, 0, 1})
+			continue
+		}
+
+		sweep.items = append(sweep.items, NewTween(sweepDuration, Linear, clock.SIMPLE,
+			func(t float32) {
+				button.Set(sprite, LerpVec4(color, vec4{1, 1, 0, 1}, t))
+			}))
+	}
+
+	if len(sweep.items) > 0 {
+		sweep.Reset()
+		animations.Add(sweep)
+	}
 }
 
-func DrawText(pos vec2, size float32, c rune) {
-	rect := vec4{float32(c-'0') * 8, 0, 8, 8}
+// SetRules lays out a fresh grid for a new board size, win length or variant.
+func SetRules(r game.Rules) {
+	size := float32(r.Size)
+
+	rules = r
+	board = game.New(r, game.X)
+
+	if r.Variant == game.Ultimate {
+		engine.buttons = AddUltimateButtons()
+	} else {
+		subBoards = nil
+		engine.buttons = AddGridButtons(size, size, GridSquareSize(size, size))
+	}
+}
 
-	defaultShader.SetMat4("uModel", getModel(pos, vec2{size, size}))
-	defaultShader.SetVec4("uOffset", fontTexture.Coords(rect))
-	defaultShader.SetVec4("uColor", vec4{1, 1, 1, 1})
+// SetSide makes the cursor and side icons match the side we play.
+func SetSide(s game.Side) {
+	side = int8(s)
+	player.sprite, player.color = SideSprite(s)
+}
 
-	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+// SideSprite returns the spritesheet cell and tint used to draw a mark.
+func SideSprite(s game.Side) (vec4, vec4) {
+	switch s {
+	case game.X:
+		return defaultTexture.Coords(vec4{0, 0, 16, 16}), vec4{0, 1, 0, 1}
+	case game.O:
+		return defaultTexture.Coords(vec4{16, 0, 16, 16}), vec4{1, 0, 0, 1}
+	default:
+		return vec4{0, 0, 0, 0}, vec4{0, 0, 1, 1}
+	}
 }
 
 var side int8 = 0
 
+// roster is who is in the room: the names of both sides and how many are
+// watching.
+var roster protocol.Roster
+
+// Spectating reports whether we are only watching the game.
+func Spectating() bool {
+	return game.Side(side) == game.None
+}
+
+// Sides returns the side shown on the left and the one on the right: ours
+// and the opponent's, or X and O when watching.
+func Sides() (game.Side, game.Side) {
+	if Spectating() {
+		return game.X, game.O
+	}
+
+	return game.Side(side), game.Side(side).Other()
+}
+
+// SideName is the name of the player of s, or the side itself when the
+// player did not give one.
+func SideName(s game.Side) string {
+	if s != game.X && s != game.O {
+		return ""
+	}
+
+	if roster.Names[s] != "" {
+		return roster.Names[s]
+	}
+
+	return [2]string{"X", "O"}[s]
+}
+
+// Score is how many rounds s has won.
+func Score(s game.Side) uint8 {
+	if s == game.O {
+		return engine.score2
+	}
+
+	return engine.score1
+}
+
+// How many characters of a name fit along the bottom.
+const nameWidth = 16
+
+// DrawSides draws our icon on the left and the opponent's on the right, the
+// one of the side to move underlined, with the names of both players under
+// the board and how many are watching between them.
 func DrawSides() {
 	left, right, size := vec2{0, 0}, vec2{W - 16, 0}, vec2{16, 16}
 
-	defaultShader.SetMat4("uModel", getModel(left, vec2{16, 16}))
+	us, them := Sides()
 
-	if side == 0 {
-		defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{0, 0, 16, 16}))
-		defaultShader.SetVec4("uColor", vec4{0, 1, 0, 1})
+	sprite, color := SideSprite(us)
+	renderer.Draw(getModel(left, size), sprite, color)
 
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	sprite, color = SideSprite(them)
+	renderer.Draw(getModel(right, size), sprite, color)
 
-		defaultShader.SetMat4("uModel", getModel(right, size))
+	switch Turn() {
+	case us:
+		renderer.Draw(getModel(left.Add(vec2{0, 17}), vec2{16, 2}),
+			defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{1, 1, 0, 1})
+	case them:
+		renderer.Draw(getModel(right.Add(vec2{0, 17}), vec2{16, 2}),
+			defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{1, 1, 0, 1})
+	}
 
-		defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{16, 0, 16, 16}))
-		defaultShader.SetVec4("uColor", vec4{1, 0, 0, 1})
+	if roster == (protocol.Roster{}) {
+		return
+	}
 
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	renderer.Bind(fontTexture)
 
-	} else {
-		defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{16, 0, 16, 16}))
-		defaultShader.SetVec4("uColor", vec4{1, 0, 0, 1})
+	name := func(s game.Side) string {
+		n := SideName(s)
+
+		if len(n) > nameWidth {
+			n = n[:nameWidth]
+		}
 
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		return n
+	}
+
+	style := defaultStyle
+	style.color = vec4{.5, .5, .5, 1}
 
-		defaultShader.SetMat4("uModel", getModel(right, size))
+	DrawString(vec2{0, H - 10}, name(us), style)
 
-		defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{0, 0, 16, 16}))
-		defaultShader.SetVec4("uColor", vec4{0, 1, 0, 1})
+	style.align = AlignRight
+	DrawString(vec2{W, H - 10}, name(them), style)
 
-		gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	if roster.Spectators > 0 {
+		style.align = AlignCenter
+		DrawString(vec2{W / 2, H - 10}, fmt.Sprint(roster.Spectators, " watching"), style)
 	}
+
+	renderer.Bind(defaultTexture)
 }
 
-type Connection struct {
-	protocol, host, port string
+// TurnClocks is the client's copy of the match clocks as of the last State.
+// The clock of the side to move keeps running down from when it arrived.
+type TurnClocks struct {
+	control game.TimeControl
+	left    [2]time.Duration
+	running game.Side
+	at      time.Time
+
+	// flagged is the side that ran out of time this round, or None.
+	flagged game.Side
 }
 
-func ReadConfig() Connection {
-	file, err := os.Open("config")
+var turnClocks = NewTurnClocks(game.TimeControl{})
 
-	if err != nil {
-		fmt.Println(err)
+func NewTurnClocks(control game.TimeControl) TurnClocks {
+	return TurnClocks{
+		control: control,
+		left:    [2]time.Duration{control.Base, control.Base},
+		running: game.None,
+		flagged: game.None,
 	}
+}
 
-	defer file.Close()
+// Sync takes the clocks from a State received at now. They only run while
+// nobody has won.
+func (c *TurnClocks) Sync(left [2]uint32, turn, winner, flagged game.Side, now time.Time) {
+	for i := range left {
+		c.left[i] = time.Duration(left[i]) * time.Millisecond
+	}
 
-	scanner := bufio.NewScanner(file)
+	c.running, c.at, c.flagged = turn, now, flagged
 
-	scanner.Scan()
-	connection := Connection{protocol: scanner.Text()}
+	if winner != game.None {
+		c.running = game.None
+	}
+}
 
-	scanner.Scan()
-	connection.host = scanner.Text()
+// Left is how much time side has at now.
+func (c *TurnClocks) Left(side game.Side, now time.Time) time.Duration {
+	left := c.left[side]
 
-	scanner.Scan()
-	connection.port = scanner.Text()
+	if side == c.running {
+		left -= now.Sub(c.at)
+	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Println(err)
+	if left < 0 {
+		return 0
 	}
 
-	return connection
+	return left
 }
 
-func main() {
-	config := ReadConfig()
+// RoundWinner is who took the round, on the board or on time, None while it
+// is being played.
+func RoundWinner() game.Side {
+	if turnClocks.flagged != game.None {
+		return turnClocks.flagged.Other()
+	}
 
-	InitTicks()
-	engine.Init()
+	return board.Winner()
+}
 
-	defer sdl.Quit()
+// FormatClock writes d as minutes and seconds, rounding up so that a clock
+// only shows 0:00 once it has run out.
+func FormatClock(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
 
-	defaultShader = DefaultShader()
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
 
-	defaultShader.SetInt("uType", 1)
-	defaultShader.SetMat4("uModel", getModel(vec2{32, 32}, vec2{32, 32}))
-	defaultShader.SetVec4("uOffset", vec4{0, 0, .1, .1})
+// DrawClocks draws the time each side has left under its icon, in red once
+// it gets under ten seconds and dimmed while the other side is thinking.
+func DrawClocks() {
+	if !turnClocks.control.Timed() {
+		return
+	}
 
-	fontTexture = loadXPM("font.png")
-	defaultTexture = loadXPM("spritesheet.png")
+	now := time.Now()
+	us, them := Sides()
 
-	engine.buttons = AddGridButtons(3, 3, 16)
+	style := func(s game.Side, align Align) TextStyle {
+		style := defaultStyle
+		style.align = align
 
-	timer := Timer{
-		state:   NONE,
-		config:  SIMPLE,
-		delay:   1000,
-		current: sdl.GetTicks(),
+		if turnClocks.Left(s, now) < 10*time.Second {
+			style.color = vec4{1, 0, 0, 1}
+		}
+
+		if s != turnClocks.running {
+			style.color = style.color.Mul(vec4{.5, .5, .5, 1})
+		}
+
+		return style
 	}
 
-	player = Player{
-		id:     0,
-		pos:    vec2{W / 2, H / 2},
-		sprite: defaultTexture.Coords(vec4{0, 0, 16, 16}),
-		color:  vec4{0, 1, 0, 1},
+	DrawString(vec2{0, 20}, FormatClock(turnClocks.Left(us, now)), style(us, AlignLeft))
+	DrawString(vec2{W, 20}, FormatClock(turnClocks.Left(them, now)), style(them, AlignRight))
+}
+
+// DrawWaiting draws the pulsing square shown while there is no game to
+// show: red while waiting for an opponent, yellow while reconnecting.
+func DrawWaiting(alpha float32) {
+	color := vec4{1, 0, 0, alpha}
+
+	if reconnecting {
+		color = vec4{1, 1, 0, alpha}
 	}
 
-	var err error
+	renderer.Draw(getModel(vec2{W/2 - 16, H/2 - 16}, vec2{32, 32}),
+		defaultTexture.Coords(vec4{0, 16, 16, 16}), color)
+}
 
-	connection, err = net.Dial(config.protocol, config.host+":"+config.port)
+// DrawDebug draws the frame rate and the average frame time in the middle of
+// the top row.
+func DrawDebug() {
+	renderer.Bind(fontTexture)
 
-	if err != nil {
-		panic(err)
+	style := defaultStyle
+	style.align = AlignCenter
+	style.color = vec4{0, 1, 1, 1}
+
+	DrawString(vec2{W / 2, 4}, fmt.Sprintf("%.0f fps %.1f ms", ticks.FPS(),
+		ticks.FrameTime()*1000), style)
+}
+
+// DrawBoard draws the grid, dimmed while the opponent is moving, the cursor,
+// the side icons and the scores.
+func DrawBoard() {
+	renderer.Bind(defaultTexture)
+
+	for i := range subBoards {
+		subBoards[i].Draw()
 	}
 
-	buffer := make([]byte, 1024)
-	_, err = connection.Read(buffer)
+	for i := range engine.buttons {
+		engine.buttons[i].Draw()
+	}
 
-	if err != nil {
-		panic(err.Error())
+	if turn := Turn(); turn != game.None && turn != game.Side(side) && !Spectating() {
+		renderer.Draw(getModel(vec2{0, 0}, vec2{W, H}),
+			defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{0, 0, 0, .5})
 	}
 
-	if buffer[0] == '1' {
-		player.sprite = defaultTexture.Coords(vec4{16, 0, 16, 16})
-		player.color = vec4{1, 0, 0, 1}
-		side = 1
+	if flash > 0 {
+		renderer.Draw(getModel(vec2{0, 0}, vec2{W, H}),
+			defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{1, 0, 0, flash})
 	}
 
-	go Channel()
+	player.Draw()
+	DrawSides()
 
-	fmt.Println(rune('9') - rune('0'))
+	renderer.Bind(fontTexture)
 
-	alpha := float32(0)
-	control := false
+	right := defaultStyle
+	right.align = AlignRight
 
-	for engine.run {
-		ticks.Update()
-		engine.Event()
-		engine.Physics()
+	us, them := Sides()
 
-		timer.Update()
+	DrawString(vec2{17, 4}, strconv.Itoa(int(Score(us))), defaultStyle)
+	DrawString(vec2{W - 16, 4}, strconv.Itoa(int(Score(them))), right)
 
-		gl.ClearColor(0, 0, 0, 1)
-		gl.Clear(gl.COLOR_BUFFER_BIT)
+	DrawClocks()
+	DrawNotice()
+}
 
-		gl.BindTexture(gl.TEXTURE_2D, defaultTexture.id)
+func main() {
+	var err error
 
-		if !ready {
-			defaultShader.SetVec4("uOffset", defaultTexture.Coords(vec4{0, 16, 16, 16}))
-			defaultShader.SetMat4("uModel", getModel(vec2{W/2 - 16, H/2 - 16}, vec2{32, 32}))
-			defaultShader.SetVec4("uColor", vec4{1, 0, 0, alpha})
+	if config, err = LoadConfig(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
 
-			if !control {
-				alpha += 0.01
+		fmt.Println("[CLIENT] Error in config:", err.Error())
+		os.Exit(2)
+	}
 
-				if alpha >= 1.00 {
-					control = true
-				}
-			} else {
-				alpha -= 0.01
+	ticks = clock.NewTicks(sdlClock{})
+	engine.Init(config)
 
-				if alpha <= 0.00 {
-					control = false
-				}
-			}
+	defer sdl.Quit()
 
-			gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	defaultShader = DefaultShader()
+	renderer = GLRenderer{defaultShader, engine.window}
 
-			engine.window.GLSwap()
+	defaultShader.SetInt("uType", 1)
+	defaultShader.SetMat4("uModel", getModel(vec2{32, 32}, vec2{32, 32}))
+	defaultShader.SetVec4("uOffset", vec4{0, 0, .1, .1})
 
-			continue
-		}
+	if batch, err := NewBatchRenderer(engine.window); err != nil {
+		fmt.Println("[CLIENT] Falling back to one draw call per quad:", err.Error())
+	} else {
+		renderer = batch
+	}
 
-		for i := range engine.buttons {
-			engine.buttons[i].Draw()
-		}
+	if err := SetFont(loadXPM("font.png")); err != nil {
+		panic(err)
+	}
 
-		player.Draw()
-		DrawSides()
+	defaultTexture = loadXPM("spritesheet.png")
 
-		gl.BindTexture(gl.TEXTURE_2D, fontTexture.id)
+	SetRules(game.Classic)
 
-		pos := vec2{17, 4}
+	timer = clock.NewTimer(&simulation, clock.SIMPLE, 1000)
 
-		for _, v := range strconv.Itoa(int(engine.score1)) {
-			DrawText(pos, 8, v)
-			pos.x += 8
-		}
+	player = Player{
+		id:     0,
+		pos:    vec2{W / 2, H / 2},
+		sprite: defaultTexture.Coords(vec4{0, 0, 16, 16}),
+		color:  vec4{0, 1, 0, 1},
+	}
 
-		str := strconv.Itoa(int(engine.score2))
+	fmt.Println(rune('9') - rune('0'))
+
+	menu := &MainMenuScene{}
+	scenes.Push(menu)
+
+	switch config.mode {
+	case modeOnline:
+		menu.Choose(menuOnline)
+	case modeOffline:
+		menu.Choose(menuOffline)
+	case modeReplay:
+		menu.Choose(menuReplays)
+	}
+
+	for engine.run {
+		ticks.Update()
+		engine.Event()
+		engine.Physics()
 
-		pos.x = float32((W - 16) - (len(str) * 8))
+		Dispatch()
+		scenes.Update()
 
-		for _, v := range str {
-			DrawText(pos, 8, v)
-			pos.x += 8
+		renderer.Clear(vec4{0, 0, 0, 1})
+		renderer.Bind(defaultTexture)
+
+		scenes.Draw()
+
+		if engine.debug {
+			DrawDebug()
 		}
 
-		engine.window.GLSwap()
+		renderer.Present()
 	}
 
+	Leave()
+
 	fmt.Println("goodbye cardgame")
 }
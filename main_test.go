@@ -0,0 +1,588 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// record sets up a window-less engine drawing into a Recorder.
+func record(t *testing.T) *Recorder {
+	t.Helper()
+
+	engine = Engine{w: W, h: H}
+	engine.viewport.Resize(W, H, W, H)
+	defaultTexture = Texture{id: 1, w: 160, h: 160}
+	SetFont(Texture{id: 2, w: 80, h: 80})
+
+	recorder := &Recorder{}
+	renderer = recorder
+
+	player = Player{pos: vec2{W / 2, H / 2}}
+	reconnecting = false
+
+	SetSide(game.X)
+
+	return recorder
+}
+
+func golden(t *testing.T, name string, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != string(want) {
+		t.Errorf("frame does not match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func cells(values ...game.Side) []game.Side {
+	return values
+}
+
+func TestDrawWaiting(t *testing.T) {
+	recorder := record(t)
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	recorder.Bind(defaultTexture)
+	DrawWaiting(.5)
+
+	reconnecting = true
+	DrawWaiting(1)
+
+	golden(t, "waiting", recorder.String())
+}
+
+func TestDrawBoard(t *testing.T) {
+	recorder := record(t)
+
+	SetRules(game.Classic)
+	SetSide(game.O)
+
+	ApplyState(2, 11, cells(
+		0, 1, -1,
+		-1, 0, 1,
+		-1, -1, 0,
+	), game.X, -1)
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	DrawBoard()
+
+	golden(t, "board", recorder.String())
+}
+
+func TestDrawUltimate(t *testing.T) {
+	recorder := record(t)
+
+	SetRules(game.Rules{Size: 3, K: 3, Variant: game.Ultimate})
+
+	state := make([]game.Side, 81)
+
+	for i := range state {
+		state[i] = game.None
+	}
+
+	state[4*9+2] = game.X
+	state[2*9+4] = game.O
+
+	ApplyState(0, 0, state, game.X, 4)
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	DrawBoard()
+
+	golden(t, "ultimate", recorder.String())
+}
+
+func TestGridLayout(t *testing.T) {
+	record(t)
+
+	b := game.NewBoard(game.Rules{Size: 4, K: 3}, game.X)
+	buttons := AddGridButtons(4, 4, 8)
+	origin := buttons[0].(*SimpleButton).pos
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			pos := buttons[b.Index(x, y)].(*SimpleButton).pos
+			want := origin.Add(vec2{float32(x) * 12, float32(y) * 12})
+
+			if pos != want {
+				t.Errorf("cell at column %d, row %d drawn at %v, want %v", x, y, pos, want)
+			}
+		}
+	}
+}
+
+func TestDrawTextBox(t *testing.T) {
+	recorder := record(t)
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	recorder.Bind(fontTexture)
+
+	style := TextStyle{size: 8, color: vec4{1, 1, 0, 1}, align: AlignCenter}
+	size := DrawTextBox(vec2{0, 0}, vec2{96, 24}, "Waiting for an opponent...", style)
+
+	if size != (vec2{88, 24}) {
+		t.Errorf("text box measured %v, want {88 24}", size)
+	}
+
+	golden(t, "textbox", recorder.String())
+}
+
+// traceScene records its hooks into a shared log.
+type traceScene struct {
+	name string
+	log  *[]string
+}
+
+func (s traceScene) Enter()                  { *s.log = append(*s.log, "enter "+s.name) }
+func (s traceScene) Exit()                   { *s.log = append(*s.log, "exit "+s.name) }
+func (s traceScene) Update()                 { *s.log = append(*s.log, "update "+s.name) }
+func (s traceScene) Draw()                   {}
+func (s traceScene) Event(sdl.Event, Action) {}
+
+func TestSetFont(t *testing.T) {
+	record(t)
+	defer SetFont(Texture{id: 2, w: 80, h: 80})
+
+	if err := SetFont(Texture{id: 3, w: 128, h: 48}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 'A' is glyph 33: row 2, column 1 of a sheet 16 glyphs wide.
+	if rect := Glyph('A'); rect != (vec4{8, 16, 8, 8}) || fontTexture.id != 3 {
+		t.Errorf("'A' at %v in texture %d, want {8 16 8 8} in 3", rect, fontTexture.id)
+	}
+
+	if err := SetFont(Texture{id: 4, w: 128, h: 40}); err == nil {
+		t.Error("took a font too short for its glyphs")
+	}
+
+	if err := SetFont(Texture{id: 5, w: 4, h: 800}); err == nil {
+		t.Error("took a font narrower than a glyph")
+	}
+
+	if fontTexture.id != 3 {
+		t.Errorf("a font that failed replaced texture 3 with %d", fontTexture.id)
+	}
+}
+
+func TestSceneStack(t *testing.T) {
+	var log []string
+	var stack SceneStack
+
+	stack.Push(traceScene{"menu", &log})
+	stack.Replace(traceScene{"game", &log})
+	stack.Push(traceScene{"waiting", &log})
+	stack.Update()
+	stack.Pop()
+	stack.Update()
+	stack.Reset(traceScene{"menu", &log})
+
+	want := []string{
+		"enter menu", "exit menu", "enter game", "enter waiting",
+		"update waiting", "exit waiting", "update game", "exit game",
+		"enter menu",
+	}
+
+	if strings.Join(log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got %v, want %v", log, want)
+	}
+}
+
+func TestGameOverScene(t *testing.T) {
+	recorder := record(t)
+
+	SetRules(game.Classic)
+	ApplyState(1, 0, cells(
+		0, 0, 0,
+		1, 1, -1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	ready = true
+	defer func() { ready = false }()
+
+	scenes = SceneStack{}
+	scenes.Push(&GameScene{})
+	scenes.Update()
+
+	if _, ok := scenes.Top().(*GameOverScene); !ok {
+		t.Fatalf("finished round shows %T, want *GameOverScene", scenes.Top())
+	}
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	scenes.Draw()
+
+	golden(t, "gameover", recorder.String())
+
+	ApplyState(1, 0, cells(
+		-1, -1, -1,
+		-1, -1, -1,
+		-1, -1, -1,
+	), game.X, -1)
+	scenes.Update()
+
+	if _, ok := scenes.Top().(*GameScene); !ok {
+		t.Errorf("next round shows %T, want *GameScene", scenes.Top())
+	}
+}
+
+func TestLossOnTime(t *testing.T) {
+	record(t)
+
+	SetRules(game.Classic)
+	SetSide(game.X)
+	ApplyState(0, 1, cells(
+		0, -1, -1,
+		-1, 1, -1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	ready = true
+	turnClocks = NewTurnClocks(game.TimeControl{Base: time.Minute})
+
+	defer func() {
+		ready = false
+		turnClocks = NewTurnClocks(game.TimeControl{})
+	}()
+
+	now := time.Now()
+
+	turnClocks.Sync([2]uint32{61500, 42000}, game.X, game.None, game.None, now)
+
+	if got := FormatClock(turnClocks.Left(game.X, now.Add(time.Second))); got != "1:01" {
+		t.Errorf("running clock shows %s, want 1:01", got)
+	}
+
+	if got := FormatClock(turnClocks.Left(game.O, now.Add(time.Second))); got != "0:42" {
+		t.Errorf("waiting clock shows %s, want 0:42", got)
+	}
+
+	turnClocks.Sync([2]uint32{0, 42000}, game.X, game.O, game.X, now)
+
+	scenes = SceneStack{}
+	scenes.Push(&GameScene{})
+	scenes.Update()
+
+	over, ok := scenes.Top().(*GameOverScene)
+
+	if !ok {
+		t.Fatalf("flagged round shows %T, want *GameOverScene", scenes.Top())
+	}
+
+	if banner := over.Banner(); banner != "You lose on time" {
+		t.Errorf("banner %q, want You lose on time", banner)
+	}
+}
+
+func TestTurnInput(t *testing.T) {
+	recorder := record(t)
+
+	animations = Animations{}
+	flash = 0
+	offline = true
+	ready = true
+
+	defer func() { offline, ready = false, false }()
+
+	SetRules(game.Classic)
+	ApplyState(0, 0, cells(
+		0, -1, -1,
+		-1, -1, -1,
+		-1, -1, -1,
+	), game.O, -1)
+
+	if MyTurn() {
+		t.Fatal("took input on the opponent's turn")
+	}
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	DrawBoard()
+
+	if !strings.Contains(recorder.String(), "size {320 180} offset {0 0.1 0.1 0.1} color {0 0 0 0.5}") {
+		t.Error("board is not dimmed on the opponent's turn")
+	}
+
+	PlaceMark(4)
+
+	if flash != 0 {
+		t.Error("a click out of turn flashed")
+	}
+
+	ApplyState(0, 0, cells(
+		0, 1, -1,
+		-1, -1, -1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	if !MyTurn() {
+		t.Fatal("no input on our turn")
+	}
+
+	PlaceMark(1)
+
+	if flash != flashAlpha {
+		t.Fatalf("a taken cell flashed %v, want %v", flash, flashAlpha)
+	}
+
+	animations.Update(flashDuration)
+
+	if flash != 0 || animations.Len() != 0 {
+		t.Errorf("flash still at %v after %vs", flash, flashDuration)
+	}
+}
+
+func TestSpectating(t *testing.T) {
+	recorder := record(t)
+
+	ready = true
+	roster = protocol.Roster{Names: [2]string{"maria", ""}, Spectators: 3}
+
+	defer func() {
+		ready = false
+		roster = protocol.Roster{}
+	}()
+
+	SetRules(game.Classic)
+	SetSide(game.None)
+	ApplyState(1, 4, cells(
+		0, -1, -1,
+		-1, 1, -1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	if us, them := Sides(); us != game.X || them != game.O {
+		t.Errorf("watching shows %v and %v, want X and O", us, them)
+	}
+
+	if MyTurn() {
+		t.Error("a spectator can move")
+	}
+
+	recorder.Clear(vec4{0, 0, 0, 1})
+	DrawBoard()
+
+	frame := recorder.String()
+
+	if strings.Contains(frame, "color {0 0 0 0.5}") {
+		t.Error("board dimmed for a spectator")
+	}
+
+	// "3 watching" is ten glyphs centered on the middle of the bottom row.
+	if !strings.Contains(frame, fmt.Sprintf("pos {%v %v}", W/2-40, H-10)) {
+		t.Error("spectator count not drawn")
+	}
+
+	over := &GameOverScene{winner: game.X, onTime: true}
+
+	if banner := over.Banner(); banner != "maria wins on time" {
+		t.Errorf("banner %q, want maria wins on time", banner)
+	}
+
+	if banner := (&GameOverScene{winner: game.O}).Banner(); banner != "O wins" {
+		t.Errorf("banner %q, want O wins", banner)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	record(t)
+
+	SetRules(game.Rules{Size: 4, K: 3})
+
+	for i := 0; i < 16; i++ {
+		if x, y := GridPos(i); GridCell(x, y) != i {
+			t.Errorf("cell %d goes to %d,%d and back to %d", i, x, y, GridCell(x, y))
+		}
+	}
+
+	focus := Focus{-1}
+
+	focus.Move(1, 0, engine.buttons)
+
+	if focus.cell != GridCell(2, 2) {
+		t.Errorf("hidden focus showed up at %d, want the middle", focus.cell)
+	}
+
+	focus.Move(5, -5, engine.buttons)
+
+	if focus.cell != GridCell(3, 0) {
+		t.Errorf("focus went to %d, want it stopped at %d", focus.cell, GridCell(3, 0))
+	}
+
+	if focus.Numpad(7, engine.buttons) {
+		t.Error("numpad picked a cell on a 4 by 4 board")
+	}
+
+	SetRules(game.Rules{Size: 3, K: 3, Variant: game.Ultimate})
+
+	focus = Focus{-1}
+	focus.Move(0, 1, engine.buttons)
+
+	if focus.cell != 4*9+4 {
+		t.Errorf("focus showed up at %d, want the middle of the middle board", focus.cell)
+	}
+
+	focus.Move(-1, -1, engine.buttons)
+
+	if focus.cell != 4*9+0 {
+		t.Errorf("focus went to %d, want the corner of the middle board", focus.cell)
+	}
+
+	focus.Move(-1, 0, engine.buttons)
+
+	if focus.cell != 3*9+2 {
+		t.Errorf("focus went to %d, want into the left board", focus.cell)
+	}
+
+	if !focus.Numpad(3, engine.buttons) || focus.cell != 3*9+8 {
+		t.Errorf("numpad 3 focused %d, want the bottom right of the left board", focus.cell)
+	}
+
+	if !engine.buttons[3*9+8].(*SimpleButton).border {
+		t.Error("focused cell is not outlined")
+	}
+}
+
+func TestChannel(t *testing.T) {
+	record(t)
+
+	SetRules(game.Classic)
+
+	server, client := net.Pipe()
+	session := &Session{conn: client, dec: protocol.NewDecoder(client)}
+	inbox, done = make(chan event, inboxSize), make(chan struct{})
+
+	defer func() {
+		close(done)
+		server.Close()
+		inbox, done, ready = nil, nil, false
+	}()
+
+	go Channel(Connection{}, session, inbox, done)
+
+	state := &protocol.State{Cells: make([]int8, 16), Turn: 1, Winner: -1, Active: -1}
+
+	for i := range state.Cells {
+		state.Cells[i] = -1
+	}
+
+	state.Cells[5] = 0
+
+	enc := protocol.NewEncoder(server)
+	enc.Encode(&protocol.Ready{Size: 4, K: 3})
+	enc.Encode(state)
+
+	for deadline := time.Now().Add(time.Second); len(inbox) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages delivered, want 2", len(inbox))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if ready || len(engine.buttons) != 9 {
+		t.Fatal("messages applied before the main loop dispatched them")
+	}
+
+	Dispatch()
+
+	if !ready || len(engine.buttons) != 16 || board.Cell(5) != game.X || Turn() != game.O {
+		t.Errorf("dispatch left ready %v, %d buttons, cells %v", ready, len(engine.buttons),
+			board.Cells())
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			protocol.NewDecoder(conn).Decode()
+			protocol.NewEncoder(conn).Encode(&protocol.AssignSide{Side: 1, Token: "seat",
+				Room: "K7QX2M"})
+
+			accepted <- conn
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	config := Connection{protocol: "tcp", host: host, port: port}
+	seat := &protocol.AssignSide{Token: "seat"}
+
+	inbox, done = make(chan event, inboxSize), make(chan struct{})
+	defer func() {
+		Leave()
+		token, room = "", ""
+	}()
+
+	s := Reconnect(config, seat, inbox, done)
+	<-accepted
+
+	if s == nil || len(inbox) != 3 {
+		t.Fatalf("reconnected to %v with %d events, want a session and 3", s, len(inbox))
+	}
+
+	Dispatch()
+
+	if connection != s.conn || encoder != s.enc || reconnecting || side != int8(game.O) ||
+		token != "seat" {
+		t.Errorf("main loop kept the old session after the reconnect")
+	}
+
+	// The player leaves while the new session is on its way to the main loop.
+	late, lateDone := make(chan event, 1), make(chan struct{})
+	result := make(chan *Session)
+
+	go func() { result <- Reconnect(config, seat, late, lateDone) }()
+
+	conn := <-accepted
+	close(lateDone)
+
+	if s := <-result; s != nil {
+		t.Error("a reconnect handed over a session after the player left")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("the session that came too late is still open: %v", err)
+	}
+}
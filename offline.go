@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"math/rand"
+	"time"
+)
+
+// How long the AI pretends to think, and how long a finished round stays on
+// screen before the board is cleared.
+const (
+	aiDelay    = 400 * time.Millisecond
+	roundDelay = 2 * time.Second
+)
+
+var offline bool = false
+
+// moves carries the human's clicks to LocalChannel. It is unbuffered and
+// LocalSend never blocks, so clicks made while the AI is thinking are dropped
+// just like the server drops out of turn moves.
+var moves = make(chan int)
+
+func LocalSend(i int) {
+	select {
+	case moves <- i:
+	default:
+	}
+}
+
+// steps carries undo (-1) and redo (1) requests to LocalChannel, dropped
+// like clicks while the AI is thinking.
+var steps = make(chan int)
+
+func LocalStep(n int) {
+	select {
+	case steps <- n:
+	default:
+	}
+}
+
+// Step takes back the last move of human along with the AI's reply when n is
+// negative, or plays them again when it is positive. It reports whether the
+// board changed.
+func Step(local game.Game, human game.Side, n int) bool {
+	if n < 0 {
+		return game.Takeback(local, human) == nil
+	}
+
+	if local.Redo() != nil {
+		return false
+	}
+
+	for local.Turn() != human && local.Winner() == game.None && local.Redo() == nil {
+	}
+
+	return true
+}
+
+// wait sleeps for d and reports false if done was closed meanwhile.
+func wait(d time.Duration, done chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// LocalChannel stands in for Channel when there is no server: it owns the
+// board, drives the AI side, takes moves back and plays them again, and posts
+// the rules and every state to the main loop the way the server would until
+// done is closed.
+func LocalChannel(r game.Rules, human game.Side, difficulty game.Difficulty,
+	inbox chan<- event, done chan struct{}) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	first := game.X
+	score := [2]uint8{}
+	local := game.New(r, first)
+
+	start := &protocol.Ready{Size: uint8(r.Size), K: uint8(r.K), Variant: uint8(r.Variant)}
+
+	if !post(inbox, event{msg: start}, done) || !post(inbox, LocalState(local, score), done) {
+		return
+	}
+
+	for {
+		if local.Turn() == human {
+			select {
+			case i := <-moves:
+				if err := local.Play(human, i); err != nil {
+					continue
+				}
+			case n := <-steps:
+				if !Step(local, human, n) {
+					continue
+				}
+			case <-done:
+				return
+			}
+		} else {
+			if !wait(aiDelay, done) {
+				return
+			}
+
+			local.Play(local.Turn(), game.BestMove(local, difficulty, rng))
+		}
+
+		winner := local.Winner()
+
+		if winner == game.X || winner == game.O {
+			score[winner]++
+		}
+
+		if !post(inbox, LocalState(local, score), done) {
+			return
+		}
+
+		if winner != game.None {
+			if !wait(roundDelay, done) {
+				return
+			}
+
+			first = first.Other()
+			local = game.New(r, first)
+
+			if !post(inbox, LocalState(local, score), done) {
+				return
+			}
+		}
+	}
+}
+
+// LocalState is the event of the State the server would send for g.
+func LocalState(g game.Game, score [2]uint8) event {
+	state := &protocol.State{
+		Score:  score,
+		Cells:  make([]int8, g.Len()),
+		Turn:   int8(g.Turn()),
+		Winner: int8(g.Winner()),
+		Active: int8(g.Active()),
+	}
+
+	for i, v := range g.Cells() {
+		state.Cells[i] = int8(v)
+	}
+
+	return event{msg: state}
+}
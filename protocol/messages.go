@@ -0,0 +1,440 @@
+package protocol
+
+type Type uint8
+
+const (
+	TypeHello Type = iota + 1
+	TypeAssignSide
+	TypeReady
+	TypeMove
+	TypeState
+	TypeGameOver
+	TypeError
+	TypeListRooms
+	TypeRooms
+	TypeCreateRoom
+	TypeJoinRoom
+	TypeQuickMatch
+	TypeRoster
+	TypeTakeback
+	TypeTakebackReply
+)
+
+type Message interface {
+	Type() Type
+	encode(w *writer)
+	decode(r *reader)
+}
+
+// New returns an empty message of the given type, or nil if the type is
+// unknown.
+func New(t Type) Message {
+	switch t {
+	case TypeHello:
+		return &Hello{}
+	case TypeAssignSide:
+		return &AssignSide{}
+	case TypeReady:
+		return &Ready{}
+	case TypeMove:
+		return &Move{}
+	case TypeState:
+		return &State{}
+	case TypeGameOver:
+		return &GameOver{}
+	case TypeError:
+		return &Error{}
+	case TypeListRooms:
+		return &ListRooms{}
+	case TypeRooms:
+		return &Rooms{}
+	case TypeCreateRoom:
+		return &CreateRoom{}
+	case TypeJoinRoom:
+		return &JoinRoom{}
+	case TypeQuickMatch:
+		return &QuickMatch{}
+	case TypeRoster:
+		return &Roster{}
+	case TypeTakeback:
+		return &Takeback{}
+	case TypeTakebackReply:
+		return &TakebackReply{}
+	}
+
+	return nil
+}
+
+// Hello is the first message a client sends after connecting. Token is empty
+// for a new player, who is then in the lobby until a CreateRoom, JoinRoom or
+// QuickMatch seats it, or the session token from an earlier AssignSide to take
+// back the same seat after a dropped connection. Size, K and Variant ask for
+// a board size, win length and variant, a zero Size leaves the choice to the
+// server and a zero K makes it the whole Size. Base, Increment and PerMove ask
+// for a time control in seconds, all zero leaves that to the server too.
+type Hello struct {
+	Name    string
+	Token   string
+	Size, K uint8
+	Variant uint8
+
+	Base, Increment, PerMove uint16
+}
+
+func (*Hello) Type() Type { return TypeHello }
+
+func (m *Hello) encode(w *writer) {
+	w.str(m.Name)
+	w.str(m.Token)
+	w.u8(m.Size)
+	w.u8(m.K)
+	w.u8(m.Variant)
+	w.u16(m.Base)
+	w.u16(m.Increment)
+	w.u16(m.PerMove)
+}
+
+func (m *Hello) decode(r *reader) {
+	m.Name = r.str()
+	m.Token = r.str()
+	m.Size = r.u8()
+	m.K = r.u8()
+	m.Variant = r.u8()
+	m.Base = r.u16()
+	m.Increment = r.u16()
+	m.PerMove = r.u16()
+}
+
+// AssignSide tells a client which side it plays, the session token it needs
+// to resume the game and the join code of the room it is in. Spectators get
+// side -1 and no token.
+type AssignSide struct {
+	Side  int8
+	Token string
+	Room  string
+}
+
+func (*AssignSide) Type() Type { return TypeAssignSide }
+
+func (m *AssignSide) encode(w *writer) {
+	w.i8(m.Side)
+	w.str(m.Token)
+	w.str(m.Room)
+}
+
+func (m *AssignSide) decode(r *reader) {
+	m.Side = r.i8()
+	m.Token = r.str()
+	m.Room = r.str()
+}
+
+// Ready is sent to both players once the match has two of them, with the
+// board size, win length, variant and time control, in seconds, the match is
+// played with.
+type Ready struct {
+	Size, K uint8
+	Variant uint8
+
+	Base, Increment, PerMove uint16
+}
+
+func (*Ready) Type() Type { return TypeReady }
+
+func (m *Ready) encode(w *writer) {
+	w.u8(m.Size)
+	w.u8(m.K)
+	w.u8(m.Variant)
+	w.u16(m.Base)
+	w.u16(m.Increment)
+	w.u16(m.PerMove)
+}
+
+func (m *Ready) decode(r *reader) {
+	m.Size = r.u8()
+	m.K = r.u8()
+	m.Variant = r.u8()
+	m.Base = r.u16()
+	m.Increment = r.u16()
+	m.PerMove = r.u16()
+}
+
+// Move asks the server to place the sender's mark on a cell.
+type Move struct {
+	Cell uint16
+}
+
+func (*Move) Type() Type { return TypeMove }
+
+func (m *Move) encode(w *writer) {
+	w.u16(m.Cell)
+}
+
+func (m *Move) decode(r *reader) {
+	m.Cell = r.u16()
+}
+
+// Why a round ended.
+const (
+	ReasonBoard uint8 = iota
+	ReasonTime
+)
+
+// State is a full snapshot of the match. Cells hold the side that owns each
+// cell or -1 when empty, Winner is -1 while the round is running and Reason
+// says how it was won. Active is the Ultimate sub-board the next move must go
+// to, -1 for any. Clock is the time each side has left in milliseconds, the
+// one of the side to move running down from when the state was sent.
+type State struct {
+	Score  [2]uint8
+	Cells  []int8
+	Turn   int8
+	Winner int8
+	Active int8
+	Reason uint8
+	Clock  [2]uint32
+}
+
+func (*State) Type() Type { return TypeState }
+
+func (m *State) encode(w *writer) {
+	w.u8(m.Score[0])
+	w.u8(m.Score[1])
+	w.i8s(m.Cells)
+	w.i8(m.Turn)
+	w.i8(m.Winner)
+	w.i8(m.Active)
+	w.u8(m.Reason)
+	w.u32(m.Clock[0])
+	w.u32(m.Clock[1])
+}
+
+func (m *State) decode(r *reader) {
+	m.Score[0] = r.u8()
+	m.Score[1] = r.u8()
+	m.Cells = r.i8s()
+	m.Turn = r.i8()
+	m.Winner = r.i8()
+	m.Active = r.i8()
+	m.Reason = r.u8()
+	m.Clock[0] = r.u32()
+	m.Clock[1] = r.u32()
+}
+
+// GameOver closes a round. Winner is a side, or 2 for a draw, and Reason is
+// ReasonTime when the loser ran out of time.
+type GameOver struct {
+	Winner int8
+	Score  [2]uint8
+	Reason uint8
+}
+
+func (*GameOver) Type() Type { return TypeGameOver }
+
+func (m *GameOver) encode(w *writer) {
+	w.i8(m.Winner)
+	w.u8(m.Score[0])
+	w.u8(m.Score[1])
+	w.u8(m.Reason)
+}
+
+func (m *GameOver) decode(r *reader) {
+	m.Winner = r.i8()
+	m.Score[0] = r.u8()
+	m.Score[1] = r.u8()
+	m.Reason = r.u8()
+}
+
+// Error reports a rejected request or a fatal problem with the connection.
+type Error struct {
+	Text string
+}
+
+func (*Error) Type() Type { return TypeError }
+
+func (m *Error) Error() string { return m.Text }
+
+func (m *Error) encode(w *writer) {
+	w.str(m.Text)
+}
+
+func (m *Error) decode(r *reader) {
+	m.Text = r.str()
+}
+
+// ListRooms asks for the open rooms of the lobby, the server answers with
+// Rooms.
+type ListRooms struct{}
+
+func (*ListRooms) Type() Type { return TypeListRooms }
+
+func (m *ListRooms) encode(w *writer) {}
+
+func (m *ListRooms) decode(r *reader) {}
+
+// Room is a lobby room with how many players and spectators it has, and the
+// rules and the time control, in seconds, it is played with.
+type Room struct {
+	Code, Name string
+	Size, K    uint8
+	Variant    uint8
+
+	Base, Increment, PerMove uint16
+
+	Players    uint8
+	Spectators uint16
+}
+
+func (m *Room) encode(w *writer) {
+	w.str(m.Code)
+	w.str(m.Name)
+	w.u8(m.Size)
+	w.u8(m.K)
+	w.u8(m.Variant)
+	w.u16(m.Base)
+	w.u16(m.Increment)
+	w.u16(m.PerMove)
+	w.u8(m.Players)
+	w.u16(m.Spectators)
+}
+
+func (m *Room) decode(r *reader) {
+	m.Code = r.str()
+	m.Name = r.str()
+	m.Size = r.u8()
+	m.K = r.u8()
+	m.Variant = r.u8()
+	m.Base = r.u16()
+	m.Increment = r.u16()
+	m.PerMove = r.u16()
+	m.Players = r.u8()
+	m.Spectators = r.u16()
+}
+
+// Rooms lists the public rooms, those with a free seat to play and the full
+// ones to watch.
+type Rooms struct {
+	Rooms []Room
+}
+
+func (*Rooms) Type() Type { return TypeRooms }
+
+func (m *Rooms) encode(w *writer) {
+	if len(m.Rooms) > MaxFrame {
+		w.err = ErrTooLarge
+		return
+	}
+
+	w.u16(uint16(len(m.Rooms)))
+
+	for i := range m.Rooms {
+		m.Rooms[i].encode(w)
+	}
+}
+
+func (m *Rooms) decode(r *reader) {
+	n := int(r.u16())
+
+	for i := 0; i < n && r.err == nil; i++ {
+		room := Room{}
+		room.decode(r)
+
+		m.Rooms = append(m.Rooms, room)
+	}
+}
+
+// CreateRoom opens a room with the rules and time control asked for in Hello
+// and seats the sender in it. A private room is left out of Rooms, it can
+// only be joined with its code.
+type CreateRoom struct {
+	Name    string
+	Private bool
+}
+
+func (*CreateRoom) Type() Type { return TypeCreateRoom }
+
+func (m *CreateRoom) encode(w *writer) {
+	w.str(m.Name)
+	w.bool(m.Private)
+}
+
+func (m *CreateRoom) decode(r *reader) {
+	m.Name = r.str()
+	m.Private = r.bool()
+}
+
+// JoinRoom takes the free seat of the room with the given code, or with
+// Watch a spectator's place in it, read only.
+type JoinRoom struct {
+	Code  string
+	Watch bool
+}
+
+func (*JoinRoom) Type() Type { return TypeJoinRoom }
+
+func (m *JoinRoom) encode(w *writer) {
+	w.str(m.Code)
+	w.bool(m.Watch)
+}
+
+func (m *JoinRoom) decode(r *reader) {
+	m.Code = r.str()
+	m.Watch = r.bool()
+}
+
+// QuickMatch queues the sender to be paired with the next player asking for
+// the same rules and time control.
+type QuickMatch struct{}
+
+func (*QuickMatch) Type() Type { return TypeQuickMatch }
+
+func (m *QuickMatch) encode(w *writer) {}
+
+func (m *QuickMatch) decode(r *reader) {}
+
+// Roster is sent to everyone in a room when someone joins or leaves it, with
+// the names of both players and how many are watching.
+type Roster struct {
+	Names      [2]string
+	Spectators uint16
+}
+
+func (*Roster) Type() Type { return TypeRoster }
+
+func (m *Roster) encode(w *writer) {
+	w.str(m.Names[0])
+	w.str(m.Names[1])
+	w.u16(m.Spectators)
+}
+
+func (m *Roster) decode(r *reader) {
+	m.Names[0] = r.str()
+	m.Names[1] = r.str()
+	m.Spectators = r.u16()
+}
+
+// Takeback asks the opponent to let the sender take back its last move,
+// along with the reply made to it if there was one. The server passes it on
+// to the opponent, who answers with TakebackReply.
+type Takeback struct{}
+
+func (*Takeback) Type() Type { return TypeTakeback }
+
+func (m *Takeback) encode(w *writer) {}
+
+func (m *Takeback) decode(r *reader) {}
+
+// TakebackReply answers a Takeback. The server passes it on to the player
+// who asked, and on Accept takes the moves back and sends a new State.
+type TakebackReply struct {
+	Accept bool
+}
+
+func (*TakebackReply) Type() Type { return TypeTakebackReply }
+
+func (m *TakebackReply) encode(w *writer) {
+	w.bool(m.Accept)
+}
+
+func (m *TakebackReply) decode(r *reader) {
+	m.Accept = r.bool()
+}
@@ -0,0 +1,301 @@
+// Package protocol implements the framed wire format spoken between the
+// client and the server.
+//
+// Every frame is a big endian uint16 length followed by that many bytes: a
+// version byte, a message type byte and the message payload. Frames with an
+// unknown version or type, a truncated payload or trailing garbage are
+// rejected, so every valid message has exactly one encoding.
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const Version uint8 = 8
+
+// MaxFrame is the largest body a frame can carry.
+const MaxFrame = 1<<16 - 1
+
+var (
+	ErrVersion  = errors.New("protocol: unsupported version")
+	ErrType     = errors.New("protocol: unknown message type")
+	ErrShort    = errors.New("protocol: message too short")
+	ErrTrailing = errors.New("protocol: trailing bytes after message")
+	ErrTooLarge = errors.New("protocol: message too large")
+	ErrValue    = errors.New("protocol: invalid value")
+)
+
+// Malformed reports whether err was caused by a bad frame rather than by the
+// underlying stream, in which case a Decoder can carry on with the next one.
+func Malformed(err error) bool {
+	return errors.Is(err, ErrVersion) || errors.Is(err, ErrType) ||
+		errors.Is(err, ErrShort) || errors.Is(err, ErrTrailing) ||
+		errors.Is(err, ErrValue)
+}
+
+// Marshal encodes m into a complete frame, length prefix included.
+func Marshal(m Message) ([]byte, error) {
+	w := writer{buf: make([]byte, 2, 32)}
+
+	w.u8(Version)
+	w.u8(uint8(m.Type()))
+
+	m.encode(&w)
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	if len(w.buf)-2 > MaxFrame {
+		return nil, ErrTooLarge
+	}
+
+	binary.BigEndian.PutUint16(w.buf, uint16(len(w.buf)-2))
+
+	return w.buf, nil
+}
+
+// Unmarshal decodes a frame body, that is a frame without its length prefix.
+func Unmarshal(body []byte) (Message, error) {
+	r := reader{buf: body}
+
+	version, kind := r.u8(), Type(r.u8())
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if version != Version {
+		return nil, fmt.Errorf("%w %d", ErrVersion, version)
+	}
+
+	m := New(kind)
+
+	if m == nil {
+		return nil, fmt.Errorf("%w %d", ErrType, kind)
+	}
+
+	m.decode(&r)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if len(r.buf) > 0 {
+		return nil, ErrTrailing
+	}
+
+	return m, nil
+}
+
+// Encoder writes frames to a stream. It is safe for concurrent use and every
+// frame goes out in a single Write, so messages never interleave.
+type Encoder struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+func (e *Encoder) Encode(m Message) error {
+	frame, err := Marshal(m)
+
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, err = e.w.Write(frame)
+
+	return err
+}
+
+// Decoder reads frames from a stream, however the bytes were split into
+// reads on the way.
+type Decoder struct {
+	r    io.Reader
+	body []byte
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, body: make([]byte, 256)}
+}
+
+// Decode blocks until a whole frame has arrived. A malformed frame is
+// reported as an error but leaves the stream in sync for the next one.
+func (d *Decoder) Decode() (Message, error) {
+	var prefix [2]byte
+
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	n := int(binary.BigEndian.Uint16(prefix[:]))
+
+	if n > cap(d.body) {
+		d.body = make([]byte, n)
+	}
+
+	body := d.body[:n]
+
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return Unmarshal(body)
+}
+
+type writer struct {
+	buf []byte
+	err error
+}
+
+func (w *writer) u8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *writer) i8(v int8) {
+	w.u8(uint8(v))
+}
+
+func (w *writer) bool(v bool) {
+	if v {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *writer) u16(v uint16) {
+	w.buf = binary.BigEndian.AppendUint16(w.buf, v)
+}
+
+func (w *writer) u32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *writer) bytes(v []byte) {
+	if len(v) > MaxFrame {
+		w.err = ErrTooLarge
+		return
+	}
+
+	w.u16(uint16(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *writer) str(v string) {
+	w.bytes([]byte(v))
+}
+
+func (w *writer) i8s(v []int8) {
+	if len(v) > MaxFrame {
+		w.err = ErrTooLarge
+		return
+	}
+
+	w.u16(uint16(len(v)))
+
+	for _, x := range v {
+		w.i8(x)
+	}
+}
+
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.buf) < n {
+		r.err = ErrShort
+		r.buf = nil
+		return nil
+	}
+
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+func (r *reader) u8() uint8 {
+	if v := r.take(1); v != nil {
+		return v[0]
+	}
+
+	return 0
+}
+
+func (r *reader) i8() int8 {
+	return int8(r.u8())
+}
+
+// bool only takes 0 and 1, so that true has a single encoding.
+func (r *reader) bool() bool {
+	v := r.u8()
+
+	if v > 1 && r.err == nil {
+		r.err = ErrValue
+	}
+
+	return v == 1
+}
+
+func (r *reader) u16() uint16 {
+	if v := r.take(2); v != nil {
+		return binary.BigEndian.Uint16(v)
+	}
+
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if v := r.take(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+
+	return 0
+}
+
+func (r *reader) bytes() []byte {
+	n := int(r.u16())
+
+	return r.take(n)
+}
+
+func (r *reader) str() string {
+	return string(r.bytes())
+}
+
+func (r *reader) i8s() []int8 {
+	raw := r.bytes()
+
+	if raw == nil {
+		return nil
+	}
+
+	v := make([]int8, len(raw))
+
+	for i, x := range raw {
+		v[i] = int8(x)
+	}
+
+	return v
+}
@@ -0,0 +1,228 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+var samples = []Message{
+	&Hello{Name: "maria"},
+	&Hello{Token: "5e55101d"},
+	&Hello{Name: "gomoku", Size: 15, K: 5},
+	&Hello{},
+	&Hello{Name: "blitz", Base: 180, Increment: 2},
+	&Hello{PerMove: 10},
+	&AssignSide{Side: 1, Token: "5e55101d", Room: "K7QX2M"},
+	&Ready{Size: 3, K: 3},
+	&Ready{Size: 3, K: 3, Variant: 1},
+	&Ready{Size: 3, K: 3, Base: 300, PerMove: 15},
+	&Move{Cell: 8},
+	&Move{Cell: 224},
+	&State{
+		Score:  [2]uint8{3, 1},
+		Cells:  []int8{0, -1, 1, -1, 0, -1, 1, -1, -1},
+		Turn:   1,
+		Winner: -1,
+		Active: -1,
+	},
+	&State{
+		Score:  [2]uint8{0, 1},
+		Cells:  []int8{0, -1, -1, -1, 1, -1, -1, -1, -1},
+		Turn:   0,
+		Winner: 1,
+		Active: -1,
+		Reason: ReasonTime,
+		Clock:  [2]uint32{0, 171250},
+	},
+	&GameOver{Winner: 2, Score: [2]uint8{4, 4}},
+	&GameOver{Winner: 1, Score: [2]uint8{0, 1}, Reason: ReasonTime},
+	&Error{Text: "cell already taken"},
+	&ListRooms{},
+	&Rooms{},
+	&Rooms{Rooms: []Room{
+		{Code: "K7QX2M", Name: "maria's room", Size: 3, K: 3, Players: 1},
+		{Code: "ABCDEF", Name: "blitz", Size: 15, K: 5, Base: 180, Increment: 2,
+			Players: 2, Spectators: 12},
+	}},
+	&CreateRoom{Name: "friends only", Private: true},
+	&CreateRoom{},
+	&JoinRoom{Code: "K7QX2M"},
+	&JoinRoom{Code: "ABCDEF", Watch: true},
+	&QuickMatch{},
+	&Roster{Names: [2]string{"maria", ""}, Spectators: 3},
+	&Takeback{},
+	&TakebackReply{Accept: true},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, m := range samples {
+		frame, err := Marshal(m)
+
+		if err != nil {
+			t.Fatalf("Marshal(%#v): %v", m, err)
+		}
+
+		got, err := Unmarshal(frame[2:])
+
+		if err != nil {
+			t.Fatalf("Unmarshal(%#v): %v", m, err)
+		}
+
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("round trip: got %#v, want %#v", got, m)
+		}
+	}
+}
+
+func TestDecoderSplitReads(t *testing.T) {
+	var stream bytes.Buffer
+
+	enc := NewEncoder(&stream)
+
+	for _, m := range samples {
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(iotest.OneByteReader(&stream))
+
+	for _, want := range samples {
+		got, err := dec.Decode()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	}
+
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last frame, got %v", err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		err  error
+	}{
+		{"empty", []byte{}, ErrShort},
+		{"version", []byte{Version + 1, uint8(TypeMove), 0, 1}, ErrVersion},
+		{"type", []byte{Version, 0}, ErrType},
+		{"short", []byte{Version, uint8(TypeMove), 1}, ErrShort},
+		{"trailing", []byte{Version, uint8(TypeMove), 0, 1, 0}, ErrTrailing},
+		{"cells", []byte{Version, uint8(TypeState), 0, 0, 0, 9, 1}, ErrShort},
+		{"active", []byte{Version, uint8(TypeState), 0, 0, 0, 0, 0, 0xff}, ErrShort},
+		{"private", []byte{Version, uint8(TypeCreateRoom), 0, 0, 2}, ErrValue},
+		{"rooms", []byte{Version, uint8(TypeRooms), 0, 2, 0, 0, 0, 0, 3, 3, 0, 0, 0, 0, 0, 0, 0,
+			1, 0, 0}, ErrShort},
+		{"clock", []byte{Version, uint8(TypeState), 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 1},
+			ErrShort},
+		{"accept", []byte{Version, uint8(TypeTakebackReply), 3}, ErrValue},
+	}
+
+	for _, test := range tests {
+		if _, err := Unmarshal(test.body); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	frame, _ := Marshal(&Hello{Name: "maria"})
+
+	dec := NewDecoder(bytes.NewReader(frame[:len(frame)-1]))
+
+	if _, err := dec.Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, m := range samples {
+		frame, _ := Marshal(m)
+		f.Add(frame[2:])
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		m, err := Unmarshal(body)
+
+		if err != nil {
+			return
+		}
+
+		frame, err := Marshal(m)
+
+		if err != nil {
+			t.Fatalf("Marshal(%#v): %v", m, err)
+		}
+
+		if !bytes.Equal(frame[2:], body) {
+			t.Errorf("re-encoding %#v: got %x, want %x", m, frame[2:], body)
+		}
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	var stream bytes.Buffer
+
+	enc := NewEncoder(&stream)
+
+	for _, m := range samples {
+		enc.Encode(m)
+	}
+
+	f.Add(stream.Bytes())
+	f.Add([]byte{0, 0})
+	f.Add([]byte{0xff, 0xff, Version})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec := NewDecoder(bytes.NewReader(data))
+
+		for i := 0; i < len(data); i++ {
+			if _, err := dec.Decode(); err == io.EOF || err == io.ErrUnexpectedEOF {
+				return
+			}
+		}
+	})
+}
+
+func FuzzState(f *testing.F) {
+	f.Add(uint8(0), uint8(0), []byte{0xff, 0, 1}, int8(0), int8(-1), int8(-1), uint8(0),
+		uint32(0), uint32(0))
+
+	f.Fuzz(func(t *testing.T, s1 uint8, s2 uint8, cells []byte, turn int8, winner int8,
+		active int8, reason uint8, c1 uint32, c2 uint32) {
+		m := &State{Score: [2]uint8{s1, s2}, Cells: make([]int8, len(cells)),
+			Turn: turn, Winner: winner, Active: active, Reason: reason,
+			Clock: [2]uint32{c1, c2}}
+
+		for i, v := range cells {
+			m.Cells[i] = int8(v)
+		}
+
+		frame, err := Marshal(m)
+
+		if err != nil {
+			return
+		}
+
+		got, err := Unmarshal(frame[2:])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("got %#v, want %#v", got, m)
+		}
+	})
+}
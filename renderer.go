@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/veandco/go-sdl2/sdl"
+	"strings"
+)
+
+// Renderer is everything the game needs to put a frame on screen. Every
+// sprite is the unit quad moved and scaled by model, sampling the offset
+// rectangle of the bound texture and tinted by color.
+type Renderer interface {
+	Clear(color vec4)
+	Bind(t Texture)
+	Draw(model Mat4, offset vec4, color vec4)
+	Present()
+}
+
+var renderer Renderer
+
+// GLRenderer draws straight away through the default shader, one draw call
+// per quad.
+type GLRenderer struct {
+	shader Shader
+	window *sdl.Window
+}
+
+func (r GLRenderer) Clear(color vec4) {
+	gl.ClearColor(color.x, color.y, color.z, color.w)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+func (r GLRenderer) Bind(t Texture) {
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
+}
+
+func (r GLRenderer) Draw(model Mat4, offset vec4, color vec4) {
+	r.shader.SetMat4("uModel", model)
+	r.shader.SetVec4("uOffset", offset)
+	r.shader.SetVec4("uColor", color)
+
+	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_INT, gl.PtrOffset(0))
+}
+
+func (r GLRenderer) Present() {
+	r.window.GLSwap()
+}
+
+type DrawCommand struct {
+	texture       uint32
+	model         Mat4
+	offset, color vec4
+}
+
+// Recorder keeps the draw commands of the current frame instead of drawing
+// them, so frames can be inspected without a GPU or a window.
+type Recorder struct {
+	texture    uint32
+	background vec4
+	commands   []DrawCommand
+	frames     int
+}
+
+func (r *Recorder) Clear(color vec4) {
+	r.background = color
+	r.commands = r.commands[:0]
+}
+
+func (r *Recorder) Bind(t Texture) {
+	r.texture = t.id
+}
+
+func (r *Recorder) Draw(model Mat4, offset vec4, color vec4) {
+	r.commands = append(r.commands, DrawCommand{r.texture, model, offset, color})
+}
+
+func (r *Recorder) Present() {
+	r.frames++
+}
+
+// String lists the recorded frame one quad per line. Models built by
+// getModel are shown as the position and size they encode.
+func (r *Recorder) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "clear %v\n", r.background)
+
+	for _, c := range r.commands {
+		m := c.model.data
+
+		fmt.Fprintf(&b, "tex %d pos %v size %v offset %v color %v\n", c.texture,
+			vec2{m[0][3], m[1][3]}, vec2{m[0][0], m[1][1]}, c.offset, c.color)
+	}
+
+	return b.String()
+}
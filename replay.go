@@ -0,0 +1,370 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/replay"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// tracker records the games shown on the board, online or offline, to save
+// them once they are over.
+var tracker replay.Tracker
+
+// Track feeds the board just shown to the tracker and saves the game to the
+// replays directory when winner ends it.
+func Track(winner game.Side, onTime bool) {
+	record := tracker.Update(board.Cells(), board.Turn(), winner, onTime, time.Now())
+
+	if record == nil || config.replays == "" {
+		return
+	}
+
+	go func() {
+		path, err := replay.Save(config.replays, record)
+
+		if err != nil {
+			fmt.Println("[CLIENT] Error saving replay:", err.Error())
+			return
+		}
+
+		fmt.Println("[CLIENT] Saved replay", path)
+	}()
+}
+
+var errNoReplays = errors.New("no replays saved yet")
+
+// OpenReplay loads the replay or game notation at path, or the latest one
+// in the replays directory when path is empty, and returns the path it read.
+func OpenReplay(path string) (*replay.Record, string, error) {
+	if path == "" {
+		if config.replays == "" {
+			return nil, "", errNoReplays
+		}
+
+		paths, err := replay.List(config.replays)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if len(paths) == 0 {
+			return nil, "", errNoReplays
+		}
+
+		path = paths[0]
+	}
+
+	record, err := replay.Load(path)
+
+	return record, path, err
+}
+
+// Export writes record in the game notation next to path, the file it came
+// from, and returns where it went.
+func Export(record *replay.Record, path string) (string, error) {
+	out := strings.TrimSuffix(path, filepath.Ext(path)) + replay.TextExt
+
+	return out, os.WriteFile(out, []byte(replay.Notation(record)), 0o644)
+}
+
+// The replay controls, left to right under the seek bar.
+const (
+	replayStart = iota
+	replayBack
+	replayPlay
+	replayStep
+	replayEnd
+	replaySpeed
+	replayExport
+	replayControls
+)
+
+const (
+	replayButton = 24
+	replayGap    = 4
+)
+
+// replayPace is the time between the moves of a game recorded without times.
+const replayPace = time.Second
+
+// replaySpeeds are the playback speeds the speed button goes through.
+var replaySpeeds = []float64{.5, 1, 2, 4, 8}
+
+// ReplayScene plays a recorded game back on the board, putting every move
+// through ApplyState the way a live game does. It has buttons to go to the
+// start, step back, play or pause, step forward, go to the end and change
+// the speed or export the game in the notation, and a bar to seek with. Left
+// and right step, up and down change the speed.
+type ReplayScene struct {
+	record *replay.Record
+	path   string
+
+	// timeline is the record played back, paced when its moves have no
+	// times.
+	timeline *replay.Record
+
+	// message says where the game was exported to, or why it was not.
+	message string
+
+	// shown is how many moves are on the board, at how far into the game
+	// playback is.
+	shown int
+	at    time.Duration
+
+	playing bool
+	speed   int
+	last    time.Time
+
+	buttons []Button
+	seek    *SimpleButton
+	focus   Focus
+}
+
+func (s *ReplayScene) Enter() {
+	width := float32(replayControls*(replayButton+replayGap) - replayGap)
+	left := W/2 - width/2
+
+	s.buttons = nil
+	s.focus = Focus{-1}
+
+	for i := 0; i < replayControls; i++ {
+		pos := vec2{left + float32(i*(replayButton+replayGap)), H - 15}
+		s.buttons = append(s.buttons,
+			&SimpleButton{ButtonData: ButtonData{pos, vec2{replayButton, 14}}})
+	}
+
+	s.seek = &SimpleButton{ButtonData: ButtonData{vec2{16, H - 21}, vec2{W - 32, 4}}}
+
+	s.timeline = s.record.Paced(replayPace)
+	s.speed = 1
+	s.playing = true
+	s.last = time.Now()
+
+	SetRules(s.record.Rules)
+	SetSide(game.None)
+	turnClocks = NewTurnClocks(game.TimeControl{})
+
+	s.Show(0)
+}
+
+func (s *ReplayScene) Exit() {}
+
+// Show puts the board n moves into the game.
+func (s *ReplayScene) Show(n int) {
+	n = clamp(n, 0, len(s.record.Moves))
+	shown, err := s.record.Board(n)
+
+	if err != nil {
+		fmt.Println("[CLIENT] Error replaying:", err.Error())
+		return
+	}
+
+	s.shown = n
+	ApplyState(0, 0, shown.Cells(), shown.Turn(), shown.Active())
+
+	if n == len(s.record.Moves) && s.record.OnTime {
+		turnClocks.flagged = s.record.Winner.Other()
+	} else {
+		turnClocks.flagged = game.None
+	}
+}
+
+// Seek moves playback to at and shows the moves made by then.
+func (s *ReplayScene) Seek(at time.Duration) {
+	s.at = at
+
+	if s.at > s.timeline.Duration() {
+		s.at = s.timeline.Duration()
+	}
+
+	if s.at < 0 {
+		s.at = 0
+	}
+
+	if n := s.timeline.Index(s.at); n != s.shown {
+		s.Show(n)
+	}
+}
+
+// Step pauses and shows the move dn away from the current one.
+func (s *ReplayScene) Step(dn int) {
+	s.playing = false
+
+	n := clamp(s.shown+dn, 0, len(s.record.Moves))
+
+	s.at = 0
+
+	if n > 0 {
+		s.at = s.timeline.Moves[n-1].At
+	}
+
+	s.Show(n)
+}
+
+func (s *ReplayScene) Update() {
+	now := time.Now()
+
+	if s.playing {
+		s.Seek(s.at + time.Duration(float64(now.Sub(s.last))*replaySpeeds[s.speed]))
+
+		if s.at >= s.timeline.Duration() {
+			s.playing = false
+		}
+	}
+
+	s.last = now
+}
+
+// Label returns the text of control i.
+func (s *ReplayScene) Label(i int) string {
+	switch i {
+	case replayStart:
+		return "|<"
+	case replayBack:
+		return "<-"
+	case replayPlay:
+		if s.playing {
+			return "||"
+		}
+
+		return ">"
+	case replayStep:
+		return "->"
+	case replayEnd:
+		return ">|"
+	case replaySpeed:
+		return fmt.Sprintf("x%g", replaySpeeds[s.speed])
+	default:
+		return "txt"
+	}
+}
+
+func (s *ReplayScene) Choose(i int) {
+	switch i {
+	case replayStart:
+		s.Step(-len(s.record.Moves))
+	case replayBack:
+		s.Step(-1)
+	case replayPlay:
+		if !s.playing && s.at >= s.timeline.Duration() {
+			s.Seek(0)
+		}
+
+		s.playing = !s.playing
+	case replayStep:
+		s.Step(1)
+	case replayEnd:
+		s.Step(len(s.record.Moves))
+	case replaySpeed:
+		s.speed = (s.speed + 1) % len(replaySpeeds)
+	case replayExport:
+		out, err := Export(s.record, s.path)
+
+		if err != nil {
+			fmt.Println("[CLIENT] Error exporting replay:", err.Error())
+			s.message = "Could not export the game"
+			return
+		}
+
+		fmt.Println("[CLIENT] Exported replay", out)
+		s.message = "Saved " + filepath.Base(out)
+	}
+}
+
+// Progress is how far into the game playback is, from 0 to 1.
+func (s *ReplayScene) Progress() float32 {
+	if s.timeline.Duration() == 0 {
+		return 1
+	}
+
+	return float32(s.at) / float32(s.timeline.Duration())
+}
+
+func (s *ReplayScene) Draw() {
+	DrawBoard()
+
+	renderer.Bind(defaultTexture)
+
+	blank := defaultTexture.Coords(vec4{0, 16, 16, 16})
+	bar := s.seek.ButtonData
+
+	renderer.Draw(getModel(bar.pos, bar.size), blank, vec4{.25, .25, .25, 1})
+	renderer.Draw(getModel(bar.pos, vec2{bar.size.x * s.Progress(), bar.size.y}), blank,
+		vec4{1, 1, 0, 1})
+
+	for i := range s.buttons {
+		s.buttons[i].Draw()
+	}
+
+	renderer.Bind(fontTexture)
+
+	center := defaultStyle
+	center.align = AlignCenter
+
+	for i := range s.buttons {
+		button := s.buttons[i].(*SimpleButton)
+		DrawString(vec2{button.pos.x + replayButton/2, button.pos.y + 3}, s.Label(i), center)
+	}
+
+	DrawString(vec2{W / 2, 4}, fmt.Sprintf("Move %d/%d", s.shown, len(s.record.Moves)),
+		center)
+
+	grey := defaultStyle
+	grey.color = vec4{.5, .5, .5, 1}
+
+	if s.message != "" {
+		grey.align = AlignCenter
+		DrawString(vec2{W / 2, 13}, s.message, grey)
+		grey.align = AlignLeft
+	} else if s.shown == len(s.record.Moves) {
+		DrawString(vec2{W / 2, 13}, s.record.Result(), center)
+	}
+
+	DrawString(vec2{0, 20}, s.record.Names[game.X], grey)
+
+	grey.align = AlignRight
+	DrawString(vec2{W, 20}, s.record.Names[game.O], grey)
+
+	renderer.Bind(defaultTexture)
+	player.Draw()
+}
+
+func (s *ReplayScene) Event(event sdl.Event, action Action) {
+	if _, ok := event.(*sdl.MouseMotionEvent); ok {
+		s.focus.Set(CheckButtonPress(player.pos, s.buttons), s.buttons)
+	}
+
+	switch action {
+	case ActionLeft:
+		s.Step(-1)
+	case ActionRight:
+		s.Step(1)
+	case ActionUp:
+		s.speed = clamp(s.speed+1, 0, len(replaySpeeds)-1)
+	case ActionDown:
+		s.speed = clamp(s.speed-1, 0, len(replaySpeeds)-1)
+	case ActionPlaceMark:
+		spot, ok := clicked(event)
+
+		switch {
+		case ok && s.seek.IsClicked(engine.viewport.ToWorld(spot)):
+			at := engine.viewport.ToWorld(spot).x - s.seek.pos.x
+			s.Seek(time.Duration(float64(s.timeline.Duration()) * float64(at/s.seek.size.x)))
+		case ok:
+			s.Choose(CheckButtonPress(spot, s.buttons))
+		case s.focus.cell >= 0:
+			s.Choose(s.focus.cell)
+		default:
+			s.Choose(replayPlay)
+		}
+	case ActionQuit:
+		ToMenu()
+	}
+}
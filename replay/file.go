@@ -0,0 +1,268 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"cardgame/game"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A replay file starts with Magic and a format version, then holds the
+// record as unsigned varints: the rules, the time control in seconds, the
+// player names, the start in unix seconds, the first side and the result,
+// then the moves. A move is its cell and side packed as cell<<1|side,
+// followed by the milliseconds since the move before it.
+const (
+	Magic   = "GTR"
+	Version = 1
+
+	// Ext is the extension replay files are saved with.
+	Ext = ".gtr"
+
+	maxName = 255
+
+	// maxDelay is the longest gap between moves in milliseconds, which keeps
+	// the times of a full board well clear of overflowing.
+	maxDelay = 1<<32 - 1
+)
+
+var ErrFormat = errors.New("not a replay file")
+
+// Write encodes r to w.
+func Write(w io.Writer, r *Record) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(Magic)
+	buf.WriteByte(Version)
+
+	put := func(v uint64) {
+		buf.Write(binary.AppendUvarint(nil, v))
+	}
+
+	put(uint64(r.Rules.Size))
+	put(uint64(r.Rules.K))
+	put(uint64(r.Rules.Variant))
+
+	put(uint64(r.Control.Base / time.Second))
+	put(uint64(r.Control.Increment / time.Second))
+	put(uint64(r.Control.PerMove / time.Second))
+
+	for _, name := range r.Names {
+		if len(name) > maxName {
+			name = name[:maxName]
+		}
+
+		put(uint64(len(name)))
+		buf.WriteString(name)
+	}
+
+	put(uint64(r.Start.Unix()))
+	put(uint64(r.First))
+	put(uint64(r.Winner + 1))
+
+	if r.OnTime {
+		put(1)
+	} else {
+		put(0)
+	}
+
+	put(uint64(len(r.Moves)))
+
+	last := time.Duration(0)
+
+	for _, move := range r.Moves {
+		put(uint64(move.Cell)<<1 | uint64(move.Side))
+		put(uint64((move.At - last) / time.Millisecond))
+		last = move.At
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// Read decodes a record written by Write and checks it with Validate.
+func Read(rd io.Reader) (*Record, error) {
+	br := bufio.NewReader(rd)
+
+	head := make([]byte, len(Magic)+1)
+
+	if _, err := io.ReadFull(br, head); err != nil || string(head[:len(Magic)]) != Magic {
+		return nil, ErrFormat
+	}
+
+	if head[len(Magic)] != Version {
+		return nil, fmt.Errorf("replay version %d, want %d", head[len(Magic)], Version)
+	}
+
+	var err error
+
+	get := func() uint64 {
+		if err != nil {
+			return 0
+		}
+
+		var v uint64
+		v, err = binary.ReadUvarint(br)
+
+		return v
+	}
+
+	// small reads a value that has to fit in an int, so that a corrupt file
+	// can not ask for huge allocations.
+	small := func(limit uint64) int {
+		v := get()
+
+		if err == nil && v > limit {
+			err = fmt.Errorf("%w: value %d out of range", ErrFormat, v)
+		}
+
+		if err != nil {
+			return 0
+		}
+
+		return int(v)
+	}
+
+	r := &Record{}
+
+	r.Rules.Size = small(game.MaxSize)
+	r.Rules.K = small(game.MaxSize)
+	r.Rules.Variant = game.Variant(small(255))
+
+	r.Control.Base = time.Duration(small(uint64(game.MaxTime/time.Second))) * time.Second
+	r.Control.Increment = time.Duration(small(uint64(game.MaxTime/time.Second))) * time.Second
+	r.Control.PerMove = time.Duration(small(uint64(game.MaxTime/time.Second))) * time.Second
+
+	for i := range r.Names {
+		name := make([]byte, small(maxName))
+
+		if err == nil {
+			_, err = io.ReadFull(br, name)
+		}
+
+		r.Names[i] = string(name)
+	}
+
+	r.Start = time.Unix(int64(get()), 0)
+	r.First = game.Side(small(1))
+	r.Winner = game.Side(small(3)) - 1
+	r.OnTime = small(1) == 1
+
+	moves := small(game.MaxSize * game.MaxSize)
+
+	if err == nil {
+		r.Moves = make([]Move, 0, moves)
+	}
+
+	last := time.Duration(0)
+
+	for i := 0; i < moves && err == nil; i++ {
+		packed := small(game.MaxSize * game.MaxSize << 1)
+		last += time.Duration(small(maxDelay)) * time.Millisecond
+
+		r.Moves = append(r.Moves, Move{Side: game.Side(packed & 1), Cell: packed >> 1, At: last})
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Save writes r to a new file in dir, named after the time the game started,
+// and returns its path.
+func Save(dir string, r *Record) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	stamp := r.Start.Format("20060102-150405")
+
+	for n := 0; ; n++ {
+		name := stamp
+
+		if n > 0 {
+			name = fmt.Sprintf("%s-%d", stamp, n)
+		}
+
+		path := filepath.Join(dir, name+Ext)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		err = Write(file, r)
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		return path, err
+	}
+}
+
+// Load reads the replay file at path, in either the binary format or the
+// text notation.
+func Load(path string) (*Record, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var r *Record
+
+	if bytes.HasPrefix(data, []byte(Magic)) {
+		r, err = Read(bytes.NewReader(data))
+	} else {
+		r, err = ParseNotation(string(data))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// List returns the replay files in dir, binary or text, newest first.
+func List(dir string) ([]string, error) {
+	var paths []string
+
+	for _, ext := range []string{Ext, TextExt} {
+		found, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, found...)
+	}
+
+	// The names start with the time the game started, so they sort by it.
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	return paths, nil
+}
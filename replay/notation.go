@@ -0,0 +1,381 @@
+package replay
+
+import (
+	"bufio"
+	"cardgame/game"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The text notation is laid out like chess PGN: tag lines in brackets, then
+// the numbered moves and the result.
+//
+//	[X "maria"]
+//	[O "jose"]
+//	[Date "2023.11.14"]
+//	[Time "22:13:20"]
+//	[Size "3"]
+//	[K "3"]
+//	[Variant "standard"]
+//	[TimeControl "180+2"]
+//	[First "X"]
+//	[Result "1-0"]
+//
+//	1. b2 {1.2s} b3 {3s} 2. a3 {4.5s} c3 {6s} 3. c1 {9s} 1-0
+//
+// Cells are named by column letter and row number, a1 being the bottom left
+// corner; an Ultimate board is named as the 9x9 grid it looks like. The time
+// a move was made, since the start of the game, follows it in braces. The
+// result is 1-0 when X wins, 0-1 when O wins, 1/2-1/2 for a draw and * for a
+// game that did not finish. A Termination tag of "time" marks a loss on time.
+const (
+	// TextExt is the extension of games saved in the text notation.
+	TextExt = ".gtn"
+
+	dateLayout = "2006.01.02"
+	timeLayout = "15:04:05"
+	lineWidth  = 79
+)
+
+var ErrNotation = errors.New("bad game notation")
+
+// width is how many columns and rows the cells of rules are laid out in.
+func width(rules game.Rules) int {
+	if rules.Variant == game.Ultimate {
+		return 9
+	}
+
+	return rules.Size
+}
+
+// grid returns the column and row of cell, counted from the top left.
+func grid(rules game.Rules, cell int) (int, int) {
+	if rules.Variant == game.Ultimate {
+		sub, i := cell/9, cell%9
+		return sub%3*3 + i%3, sub/3*3 + i/3
+	}
+
+	return cell % rules.Size, cell / rules.Size
+}
+
+// Coord names cell of a board played with rules, like a1 or c3.
+func Coord(rules game.Rules, cell int) string {
+	x, y := grid(rules, cell)
+
+	return fmt.Sprintf("%c%d", 'a'+x, width(rules)-y)
+}
+
+// ParseCoord returns the cell named by coord.
+func ParseCoord(rules game.Rules, coord string) (int, error) {
+	w := width(rules)
+
+	if len(coord) < 2 || coord[0] < 'a' || int(coord[0]-'a') >= w {
+		return 0, fmt.Errorf("%w: no cell %q", ErrNotation, coord)
+	}
+
+	rank, err := strconv.Atoi(coord[1:])
+
+	if err != nil || rank < 1 || rank > w || coord[1] == '0' {
+		return 0, fmt.Errorf("%w: no cell %q", ErrNotation, coord)
+	}
+
+	x, y := int(coord[0]-'a'), w-rank
+
+	if rules.Variant == game.Ultimate {
+		return (y/3*3+x/3)*9 + y%3*3 + x%3, nil
+	}
+
+	return y*w + x, nil
+}
+
+// resultToken writes winner the way the notation does.
+func resultToken(winner game.Side) string {
+	switch winner {
+	case game.X:
+		return "1-0"
+	case game.O:
+		return "0-1"
+	case game.Draw:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+func parseResult(token string) (game.Side, bool) {
+	switch token {
+	case "1-0":
+		return game.X, true
+	case "0-1":
+		return game.O, true
+	case "1/2-1/2":
+		return game.Draw, true
+	case "*":
+		return game.None, true
+	}
+
+	return game.None, false
+}
+
+// Notation writes r in the text notation.
+func Notation(r *Record) string {
+	var b strings.Builder
+
+	tag := func(key, value string) {
+		fmt.Fprintf(&b, "[%s %s]\n", key, strconv.Quote(value))
+	}
+
+	tag("X", r.Names[game.X])
+	tag("O", r.Names[game.O])
+
+	if !r.Start.IsZero() {
+		tag("Date", r.Start.UTC().Format(dateLayout))
+		tag("Time", r.Start.UTC().Format(timeLayout))
+	}
+
+	tag("Size", strconv.Itoa(r.Rules.Size))
+	tag("K", strconv.Itoa(r.Rules.K))
+	tag("Variant", r.Rules.Variant.String())
+	tag("TimeControl", r.Control.String())
+	tag("First", [2]string{"X", "O"}[r.First])
+	tag("Result", resultToken(r.Winner))
+
+	if r.OnTime {
+		tag("Termination", "time")
+	}
+
+	b.WriteString("\n")
+
+	line := 0
+
+	word := func(w string) {
+		if line > 0 && line+1+len(w) > lineWidth {
+			b.WriteString("\n")
+			line = 0
+		}
+
+		if line > 0 {
+			b.WriteString(" ")
+			line++
+		}
+
+		b.WriteString(w)
+		line += len(w)
+	}
+
+	for i, move := range r.Moves {
+		if i%2 == 0 {
+			word(strconv.Itoa(i/2+1) + ".")
+		}
+
+		word(Coord(r.Rules, move.Cell))
+		word("{" + move.At.String() + "}")
+	}
+
+	word(resultToken(r.Winner))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// ParseNotation reads a game written in the text notation and checks it
+// with Validate. Tags it does not know are skipped, missing ones default to
+// a classic game X started.
+func ParseNotation(text string) (*Record, error) {
+	r := &Record{Rules: game.Classic, First: game.X, Winner: game.None}
+
+	tags := map[string]string{}
+	var moves strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "[") {
+			moves.WriteString(line + "\n")
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimSuffix(line[1:], "]"), " ")
+
+		if !ok || !strings.HasSuffix(line, "]") {
+			return nil, fmt.Errorf("%w: line %d: bad tag %q", ErrNotation, n, line)
+		}
+
+		unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: tag %s is not quoted", ErrNotation, n, key)
+		}
+
+		tags[key] = unquoted
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.applyTags(tags); err != nil {
+		return nil, err
+	}
+
+	result, tagged := r.Winner, tags["Result"] != ""
+	side := r.First
+	ended := false
+
+	for _, token := range tokens(moves.String()) {
+		switch {
+		case ended:
+			return nil, fmt.Errorf("%w: %q after the result", ErrNotation, token)
+		case strings.HasPrefix(token, "{"):
+			if len(r.Moves) == 0 {
+				continue
+			}
+
+			// Braces that do not hold a time are comments.
+			if at, err := time.ParseDuration(strings.Trim(token, "{}")); err == nil {
+				r.Moves[len(r.Moves)-1].At = at
+			}
+		case strings.HasSuffix(token, "."):
+			if _, err := strconv.Atoi(strings.TrimRight(token, ".")); err != nil {
+				return nil, fmt.Errorf("%w: bad move number %q", ErrNotation, token)
+			}
+		default:
+			if winner, ok := parseResult(token); ok {
+				if tagged && winner != result {
+					return nil, fmt.Errorf("%w: result %s does not match the tag",
+						ErrNotation, token)
+				}
+
+				r.Winner, ended = winner, true
+				continue
+			}
+
+			cell, err := ParseCoord(r.Rules, token)
+
+			if err != nil {
+				return nil, err
+			}
+
+			at := time.Duration(0)
+
+			if len(r.Moves) > 0 {
+				at = r.Moves[len(r.Moves)-1].At
+			}
+
+			r.Moves = append(r.Moves, Move{Side: side, Cell: cell, At: at})
+			side = side.Other()
+		}
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// applyTags fills in r from the header tags.
+func (r *Record) applyTags(tags map[string]string) error {
+	var err error
+
+	number := func(key string, value *int) {
+		if v, ok := tags[key]; ok && err == nil {
+			if *value, err = strconv.Atoi(v); err != nil {
+				err = fmt.Errorf("%w: %s %q is not a number", ErrNotation, key, v)
+			}
+		}
+	}
+
+	number("Size", &r.Rules.Size)
+	r.Rules.K = r.Rules.Size
+	number("K", &r.Rules.K)
+
+	if v, ok := tags["Variant"]; ok && err == nil {
+		r.Rules.Variant, err = game.ParseVariant(v)
+	}
+
+	if v, ok := tags["TimeControl"]; ok && err == nil {
+		r.Control, err = game.ParseTimeControl(v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.Names = [2]string{tags["X"], tags["O"]}
+
+	if date, ok := tags["Date"]; ok {
+		clock := tags["Time"]
+
+		if clock == "" {
+			clock = "00:00:00"
+		}
+
+		start, err := time.ParseInLocation(dateLayout+" "+timeLayout, date+" "+clock,
+			time.UTC)
+
+		if err != nil {
+			return fmt.Errorf("%w: bad date %q %q", ErrNotation, date, clock)
+		}
+
+		r.Start = start
+	}
+
+	switch tags["First"] {
+	case "", "X":
+	case "O":
+		r.First = game.O
+	default:
+		return fmt.Errorf("%w: first side %q is not X or O", ErrNotation, tags["First"])
+	}
+
+	if v, ok := tags["Result"]; ok {
+		winner, ok := parseResult(v)
+
+		if !ok {
+			return fmt.Errorf("%w: unknown result %q", ErrNotation, v)
+		}
+
+		r.Winner = winner
+	}
+
+	r.OnTime = tags["Termination"] == "time"
+
+	return nil
+}
+
+// tokens splits movetext at spaces, keeping braced comments whole and
+// splitting move numbers off the moves they are written against, as in 1.b2.
+func tokens(text string) []string {
+	var out []string
+
+	fields := strings.Fields(text)
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		if strings.HasPrefix(field, "{") {
+			for !strings.HasSuffix(field, "}") && i+1 < len(fields) {
+				i++
+				field += " " + fields[i]
+			}
+
+			out = append(out, field)
+			continue
+		}
+
+		if dot := strings.LastIndexByte(field, '.'); dot >= 0 && dot+1 < len(field) {
+			out = append(out, field[:dot+1])
+			field = field[dot+1:]
+		}
+
+		out = append(out, field)
+	}
+
+	return out
+}
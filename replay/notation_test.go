@@ -0,0 +1,146 @@
+package replay
+
+import (
+	"cardgame/game"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoord(t *testing.T) {
+	tests := []struct {
+		rules game.Rules
+		cell  int
+		coord string
+	}{
+		{game.Classic, 0, "a3"},
+		{game.Classic, 2, "c3"},
+		{game.Classic, 6, "a1"},
+		{game.Classic, 8, "c1"},
+		{game.Rules{Size: 15, K: 5}, 0, "a15"},
+		{game.Rules{Size: 15, K: 5}, 224, "o1"},
+		{game.Rules{Size: 3, K: 3, Variant: game.Ultimate}, 0, "a9"},
+		{game.Rules{Size: 3, K: 3, Variant: game.Ultimate}, 9 + 4, "e8"},
+		{game.Rules{Size: 3, K: 3, Variant: game.Ultimate}, 80, "i1"},
+	}
+
+	for _, test := range tests {
+		if got := Coord(test.rules, test.cell); got != test.coord {
+			t.Errorf("Coord(%v, %d) = %q, want %q", test.rules, test.cell, got, test.coord)
+		}
+
+		if got, err := ParseCoord(test.rules, test.coord); err != nil || got != test.cell {
+			t.Errorf("ParseCoord(%v, %q) = %d, %v, want %d", test.rules, test.coord, got,
+				err, test.cell)
+		}
+	}
+
+	for _, bad := range []string{"", "a", "d1", "a4", "a0", "a01", "A1", "b-1"} {
+		if _, err := ParseCoord(game.Classic, bad); !errors.Is(err, ErrNotation) {
+			t.Errorf("ParseCoord(%q): %v, want %v", bad, err, ErrNotation)
+		}
+	}
+}
+
+func TestNotationRoundTrip(t *testing.T) {
+	want := sample()
+	want.OnTime = true
+	want.Moves = want.Moves[:4]
+
+	text := Notation(want)
+
+	for _, line := range []string{`[X "maria"]`, `[Date "2023.11.14"]`,
+		`[TimeControl "180+2"]`, `[Termination "time"]`,
+		"1. b2 {1.2s} b3 {3s} 2. a3 {4.5s} c3 {6s} 1-0"} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("no %q line in\n%s", line, text)
+		}
+	}
+
+	got, err := ParseNotation(text)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Start.Equal(want.Start) {
+		t.Errorf("started %v, want %v", got.Start, want.Start)
+	}
+
+	got.Start = want.Start
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+}
+
+func TestParseNotation(t *testing.T) {
+	// Pasted by hand: no times, a comment, numbers stuck to the moves and
+	// defaults for the tags that are missing.
+	text := `[O "jose"]
+[Opening "center"]
+
+1.b2 {the usual} a3 2.c3
+`
+
+	r, err := ParseNotation(text)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Rules != game.Classic || r.First != game.X || r.Names[game.O] != "jose" ||
+		r.Winner != game.None || len(r.Moves) != 3 || r.Moves[1] != (Move{game.O, 0, 0}) {
+		t.Errorf("parsed %+v", r)
+	}
+
+	paced := r.Paced(time.Second)
+
+	if paced.Duration() != 3*time.Second || paced.Index(1500*time.Millisecond) != 1 ||
+		r.Duration() != 0 {
+		t.Errorf("paced moves %+v, record moves %+v", paced.Moves, r.Moves)
+	}
+
+	if timed := sample(); timed.Paced(time.Second) != timed {
+		t.Error("paced a record that has times")
+	}
+
+	errs := map[string]string{
+		"illegal move":    "1. b2 b2",
+		"unknown cell":    "1. z9",
+		"result mismatch": "[Result \"0-1\"]\n1. b2 1-0",
+		"wrong result":    "1. a1 b1 2. a2 b2 3. a3 0-1",
+		"no line":         "1. b2 1-0",
+		"after result":    "1. b2 * a1",
+		"bad size":        "[Size \"three\"]",
+		"unquoted tag":    "[X maria]",
+		"bad move number": "one. b2",
+	}
+
+	for name, text := range errs {
+		if _, err := ParseNotation(text); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestLoadNotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shared"+TextExt)
+
+	os.WriteFile(path, []byte(Notation(sample())), 0o644)
+
+	r, err := Load(path)
+
+	if err != nil || len(r.Moves) != 5 || r.Winner != game.X {
+		t.Fatalf("loaded %+v, %v", r, err)
+	}
+
+	if paths, _ := List(dir); len(paths) != 1 || paths[0] != path {
+		t.Errorf("listed %v, want %s", paths, path)
+	}
+}
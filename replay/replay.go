@@ -0,0 +1,151 @@
+// Package replay records finished games and plays them back. A record holds
+// the rules the game was played with, who played it, every move with the
+// time it was made and how the game ended. It knows nothing about rendering
+// or the network.
+package replay
+
+import (
+	"cardgame/game"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Move is a mark placed by Side on Cell, At after the game started.
+type Move struct {
+	Side game.Side
+	Cell int
+	At   time.Duration
+}
+
+// Record is one game from the first move to the result. Winner is None for a
+// game that was not finished, OnTime is set when the loser ran out of time.
+type Record struct {
+	Rules   game.Rules
+	Control game.TimeControl
+	Names   [2]string
+	Start   time.Time
+
+	First game.Side
+	Moves []Move
+
+	Winner game.Side
+	OnTime bool
+}
+
+// Board plays the first n moves of r on a fresh board.
+func (r *Record) Board(n int) (game.Game, error) {
+	board := game.New(r.Rules, r.First)
+
+	for i, move := range r.Moves[:n] {
+		if err := board.Play(move.Side, move.Cell); err != nil {
+			return board, fmt.Errorf("move %d: %w", i+1, err)
+		}
+	}
+
+	return board, nil
+}
+
+// Duration is how long the game took, up to its last move.
+func (r *Record) Duration() time.Duration {
+	if len(r.Moves) == 0 {
+		return 0
+	}
+
+	return r.Moves[len(r.Moves)-1].At
+}
+
+// Paced returns r, or when none of its moves has a time, as in a game typed
+// in by hand, a copy of it with a move every interval so that it can be
+// played back.
+func (r *Record) Paced(interval time.Duration) *Record {
+	if r.Duration() > 0 || len(r.Moves) == 0 {
+		return r
+	}
+
+	paced := *r
+	paced.Moves = make([]Move, len(r.Moves))
+
+	for i, move := range r.Moves {
+		move.At = time.Duration(i+1) * interval
+		paced.Moves[i] = move
+	}
+
+	return &paced
+}
+
+// Index is how many moves had been made at, since the start of the game.
+func (r *Record) Index(at time.Duration) int {
+	return sort.Search(len(r.Moves), func(i int) bool {
+		return r.Moves[i].At > at
+	})
+}
+
+// Result describes how the game ended, using the player names when there
+// are any.
+func (r *Record) Result() string {
+	switch r.Winner {
+	case game.None:
+		return "Unfinished"
+	case game.Draw:
+		return "Draw"
+	}
+
+	name := r.Names[r.Winner]
+
+	if name == "" {
+		name = [2]string{"X", "O"}[r.Winner]
+	}
+
+	if r.OnTime {
+		return name + " wins on time"
+	}
+
+	return name + " wins"
+}
+
+// Validate checks that the rules hold, the moves are legal and come in order,
+// and that the result is one the last board allows: its winner, or a side
+// that won on time while the board was undecided.
+func (r *Record) Validate() error {
+	if err := r.Rules.Validate(); err != nil {
+		return err
+	}
+
+	if err := r.Control.Validate(); err != nil {
+		return err
+	}
+
+	if r.First != game.X && r.First != game.O {
+		return fmt.Errorf("first side %d is not X or O", r.First)
+	}
+
+	for i := 1; i < len(r.Moves); i++ {
+		if r.Moves[i].At < r.Moves[i-1].At {
+			return fmt.Errorf("move %d: made before the move ahead of it", i+1)
+		}
+	}
+
+	if r.Winner < game.None || r.Winner > game.Draw {
+		return fmt.Errorf("unknown winner %d", r.Winner)
+	}
+
+	board, err := r.Board(len(r.Moves))
+
+	if err != nil {
+		return err
+	}
+
+	winner := board.Winner()
+
+	if winner != game.None && winner != r.Winner {
+		return fmt.Errorf("result %d does not match the board", r.Winner)
+	}
+
+	// Without a line on the board a side can only have won on time.
+	if winner == game.None && (r.Winner == game.X || r.Winner == game.O) && !r.OnTime {
+		return fmt.Errorf("result %d is neither on the board nor on time", r.Winner)
+	}
+
+	return nil
+}
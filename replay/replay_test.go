@@ -0,0 +1,286 @@
+package replay
+
+import (
+	"bytes"
+	"cardgame/game"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sample is a classic game X wins on the diagonal.
+func sample() *Record {
+	return &Record{
+		Rules:   game.Classic,
+		Control: game.TimeControl{Base: 180 * time.Second, Increment: 2 * time.Second},
+		Names:   [2]string{"maria", "jose"},
+		Start:   time.Unix(1700000000, 0),
+		First:   game.X,
+		Moves: []Move{
+			{game.X, 4, 1200 * time.Millisecond},
+			{game.O, 1, 3 * time.Second},
+			{game.X, 0, 4500 * time.Millisecond},
+			{game.O, 2, 6 * time.Second},
+			{game.X, 8, 9 * time.Second},
+		},
+		Winner: game.X,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := Write(&buf, sample()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(bytes.NewReader(buf.Bytes()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, sample()) {
+		t.Errorf("read back %+v, want %+v", got, sample())
+	}
+
+	if buf.Len() > 48 {
+		t.Errorf("a five move game takes %d bytes", buf.Len())
+	}
+
+	for n := 0; n < buf.Len(); n++ {
+		if _, err := Read(bytes.NewReader(buf.Bytes()[:n])); err == nil {
+			t.Errorf("read a record cut at %d bytes", n)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("PNG\x01"))); err != ErrFormat {
+		t.Errorf("foreign file: %v, want %v", err, ErrFormat)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(r *Record){
+		"illegal move": func(r *Record) { r.Moves[1].Cell = 4 },
+		"out of turn":  func(r *Record) { r.Moves[1].Side = game.X },
+		"wrong result": func(r *Record) { r.Winner = game.O },
+		"time travel":  func(r *Record) { r.Moves[2].At = time.Second },
+		"bad rules":    func(r *Record) { r.Rules.K = 4 },
+		"no line":      func(r *Record) { r.Moves = r.Moves[:3] },
+		"bad winner":   func(r *Record) { r.Winner = 5 },
+	}
+
+	for name, change := range tests {
+		r := sample()
+		change(r)
+
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	if err := sample().Validate(); err != nil {
+		t.Errorf("sample: %v", err)
+	}
+
+	r := sample()
+	r.Moves, r.Winner, r.OnTime = r.Moves[:3], game.O, true
+
+	if err := r.Validate(); err != nil {
+		t.Errorf("won on time: %v", err)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	r := sample()
+
+	tests := []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 0},
+		{time.Second, 0},
+		{1200 * time.Millisecond, 1},
+		{5 * time.Second, 3},
+		{time.Minute, 5},
+	}
+
+	for _, test := range tests {
+		if got := r.Index(test.at); got != test.want {
+			t.Errorf("Index(%v) = %d, want %d", test.at, got, test.want)
+		}
+	}
+
+	board, err := r.Board(3)
+
+	if err != nil || board.Cell(0) != game.X || board.Cell(2) != game.None ||
+		board.Turn() != game.O {
+		t.Errorf("Board(3) = %v, %v", board.Cells(), err)
+	}
+
+	if r.Duration() != 9*time.Second || r.Result() != "maria wins" {
+		t.Errorf("%v long, %q", r.Duration(), r.Result())
+	}
+}
+
+func TestTracker(t *testing.T) {
+	want := sample()
+	tracker := Tracker{Rules: want.Rules, Control: want.Control, Names: want.Names}
+
+	board := game.New(want.Rules, want.First)
+
+	if tracker.Update(board.Cells(), board.Turn(), game.None, false, want.Start) != nil {
+		t.Fatal("a record came out of an empty board")
+	}
+
+	var got *Record
+
+	for i, move := range want.Moves {
+		board.Play(move.Side, move.Cell)
+
+		now := want.Start.Add(move.At)
+
+		// The same state twice, as after a reconnect, is not a move.
+		tracker.Update(board.Cells(), board.Turn(), game.None, false, now)
+		got = tracker.Update(board.Cells(), board.Turn(), board.Winner(), false, now)
+
+		if got != nil && i < len(want.Moves)-1 {
+			t.Fatalf("a record came out after move %d", i+1)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked %+v, want %+v", got, want)
+	}
+
+	// Joining halfway through loses the start of the game, so nothing is
+	// recorded until the next one.
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, want.Start)
+	board, _ = sample().Board(2)
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, want.Start)
+
+	if tracker.Update(board.Cells(), board.Turn(), game.O, true, want.Start) != nil {
+		t.Error("recorded a game joined halfway through")
+	}
+}
+
+func TestSaveList(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := Save(dir, sample())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := sample()
+	later.Start = later.Start.Add(time.Hour)
+	second, _ := Save(dir, later)
+	again, _ := Save(dir, later)
+
+	if first == second || second == again {
+		t.Errorf("saved over another replay: %s, %s, %s", first, second, again)
+	}
+
+	paths, err := List(dir)
+
+	if err != nil || len(paths) != 3 || paths[2] != first {
+		t.Errorf("listed %v, %v, want the first game last", paths, err)
+	}
+
+	if r, err := Load(first); err != nil || !reflect.DeepEqual(r, sample()) {
+		t.Errorf("loaded %+v, %v", r, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "cut"+Ext), []byte(Magic+"\x01\x03"), 0o644)
+
+	if _, err := Load(filepath.Join(dir, "cut"+Ext)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("loading a cut file: %v", err)
+	}
+}
+
+func TestTrackerTakeback(t *testing.T) {
+	tracker := Tracker{Rules: game.Classic}
+	start := time.Unix(1700000000, 0)
+
+	board := game.New(game.Classic, game.X)
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+
+	for _, i := range []int{4, 0, 8} {
+		board.Play(board.Turn(), i)
+		tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+	}
+
+	// Both of the last two moves go in one snapshot, as after an online
+	// takeback.
+	game.Takeback(board, game.O)
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+
+	board.Play(game.O, 2)
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+
+	for _, i := range []int{8, 1, 6} {
+		board.Play(board.Turn(), i)
+	}
+
+	// A snapshot that jumps ahead several moves loses the game.
+	if r := tracker.Update(board.Cells(), board.Turn(), board.Winner(), false, start); r != nil {
+		t.Errorf("recorded %+v across a jump", r)
+	}
+
+	board, _ = (&Record{Rules: game.Classic, First: game.X}).Board(0)
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+
+	for _, i := range []int{4, 0} {
+		board.Play(board.Turn(), i)
+		tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+	}
+
+	board.Undo()
+	tracker.Update(board.Cells(), board.Turn(), game.None, false, start)
+
+	for _, i := range []int{1, 0, 2, 8} {
+		board.Play(board.Turn(), i)
+		r := tracker.Update(board.Cells(), board.Turn(), board.Winner(), false, start)
+
+		if i == 8 && (r == nil || len(r.Moves) != 5 || r.Moves[1].Cell != 1) {
+			t.Errorf("recorded %+v, want the game without the move taken back", r)
+		}
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	var buf bytes.Buffer
+
+	Write(&buf, sample())
+	f.Add(buf.Bytes())
+	f.Add([]byte(Magic))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := Read(bytes.NewReader(data))
+
+		if err != nil {
+			return
+		}
+
+		var out bytes.Buffer
+
+		if err := Write(&out, r); err != nil {
+			t.Fatalf("Write(%+v): %v", r, err)
+		}
+
+		back, err := Read(&out)
+
+		if err != nil {
+			t.Fatalf("reading back %+v: %v", r, err)
+		}
+
+		if !reflect.DeepEqual(back, r) {
+			t.Errorf("read back %+v, want %+v", back, r)
+		}
+	})
+}
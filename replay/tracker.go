@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"cardgame/game"
+	"time"
+)
+
+// Tracker builds records out of the board snapshots a player is shown, so a
+// client can record games it only sees as states. A record starts with an
+// empty board and grows a move for every snapshot that is one mark on from
+// the last, and loses the last moves again when a snapshot takes them back.
+// Snapshots that jump any other way, such as after resuming a game, lose
+// track of the game until the next one starts.
+type Tracker struct {
+	Rules   game.Rules
+	Control game.TimeControl
+	Names   [2]string
+
+	record *Record
+	cells  []game.Side
+}
+
+// Update takes the snapshot shown at now and returns the record of the game
+// once winner says it is over, nil until then.
+func (t *Tracker) Update(cells []game.Side, turn game.Side, winner game.Side, onTime bool,
+	now time.Time) *Record {
+	previous := t.cells
+	t.cells = append(t.cells[:0:0], cells...)
+
+	if empty(cells) && winner == game.None {
+		t.record = &Record{
+			Rules:   t.Rules,
+			Control: t.Control,
+			Names:   t.Names,
+			Start:   now,
+			First:   turn,
+			Winner:  game.None,
+		}
+
+		return nil
+	}
+
+	if t.record == nil {
+		return nil
+	}
+
+	placed, changed := []int{}, 0
+	removed := map[int]bool{}
+
+	if len(previous) == len(cells) {
+		for i := range cells {
+			switch {
+			case cells[i] == previous[i]:
+				continue
+			case previous[i] == game.None:
+				placed = append(placed, i)
+			case cells[i] == game.None:
+				removed[i] = true
+			}
+
+			changed++
+		}
+	}
+
+	switch {
+	case len(previous) != len(cells) || changed != len(placed)+len(removed) ||
+		len(placed) > 1 || (len(placed) == 1 && len(removed) > 0):
+		t.record = nil
+		return nil
+	case len(placed) == 1:
+		t.record.Moves = append(t.record.Moves, Move{
+			Side: cells[placed[0]],
+			Cell: placed[0],
+			At:   now.Sub(t.record.Start),
+		})
+	case len(removed) > 0:
+		if !t.takeBack(removed) {
+			t.record = nil
+			return nil
+		}
+	}
+
+	if winner == game.None {
+		return nil
+	}
+
+	record := t.record
+	record.Winner, record.OnTime = winner, onTime
+	t.record = nil
+
+	return record
+}
+
+// takeBack drops the last moves of the record if they are the removed
+// cells, and reports whether they were.
+func (t *Tracker) takeBack(removed map[int]bool) bool {
+	moves := t.record.Moves
+	n := len(moves) - len(removed)
+
+	if n < 0 {
+		return false
+	}
+
+	for _, move := range moves[n:] {
+		if !removed[move.Cell] {
+			return false
+		}
+	}
+
+	t.record.Moves = moves[:n]
+
+	return true
+}
+
+func empty(cells []game.Side) bool {
+	for _, v := range cells {
+		if v != game.None {
+			return false
+		}
+	}
+
+	return true
+}
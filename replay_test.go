@@ -0,0 +1,160 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/replay"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayScene(t *testing.T) {
+	record(t)
+
+	defer SetSide(game.X)
+
+	r := &replay.Record{
+		Rules: game.Classic,
+		Names: [2]string{"maria", "jose"},
+		First: game.X,
+		Moves: []replay.Move{
+			{Side: game.X, Cell: 4, At: time.Second},
+			{Side: game.O, Cell: 0, At: 2 * time.Second},
+			{Side: game.X, Cell: 8, At: 4 * time.Second},
+		},
+		Winner: game.X,
+		OnTime: true,
+	}
+
+	s := &ReplayScene{record: r}
+	s.Enter()
+
+	if !s.playing || s.shown != 0 || board.Cell(4) != game.None || !Spectating() {
+		t.Fatalf("replay starts with %d moves shown, playing %v", s.shown, s.playing)
+	}
+
+	s.Seek(3 * time.Second)
+
+	if s.shown != 2 || board.Cell(0) != game.O || Turn() != game.X {
+		t.Errorf("seeking to 3s shows %d moves, %v", s.shown, board.Cells())
+	}
+
+	s.Choose(replayStep)
+
+	if s.playing || s.at != 4*time.Second || RoundWinner() != game.X {
+		t.Errorf("stepping to the end: at %v, winner %v", s.at, RoundWinner())
+	}
+
+	s.Choose(replayBack)
+
+	if s.shown != 2 || RoundWinner() != game.None || board.Cell(8) != game.None {
+		t.Errorf("stepping back shows %d moves, %v", s.shown, board.Cells())
+	}
+
+	s.Choose(replaySpeed)
+
+	if s.Label(replaySpeed) != "x2" {
+		t.Errorf("speed shows %q, want x2", s.Label(replaySpeed))
+	}
+
+	// Playing at the end starts over.
+	s.Choose(replayEnd)
+	s.Choose(replayPlay)
+
+	if !s.playing || s.shown != 0 || s.Progress() != 0 {
+		t.Errorf("play at the end: playing %v at move %d", s.playing, s.shown)
+	}
+
+	s.last = time.Now().Add(-750 * time.Millisecond)
+	s.Update()
+
+	if s.shown != 1 {
+		t.Errorf("1.5s at x2 shows %d moves, want 1", s.shown)
+	}
+}
+
+func TestReplayUntimed(t *testing.T) {
+	record(t)
+
+	defer SetSide(game.X)
+
+	r, err := replay.ParseNotation("1. b2 a3 2. c1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ReplayScene{record: r}
+	s.Enter()
+
+	s.last = time.Now().Add(-1500 * time.Millisecond)
+	s.Update()
+
+	if !s.playing || s.shown != 1 || s.Progress() >= 1 {
+		t.Errorf("1.5s into a game without times shows %d moves, playing %v", s.shown,
+			s.playing)
+	}
+
+	s.Choose(replayEnd)
+	s.Choose(replayBack)
+
+	if s.shown != 2 || s.at != 2*replayPace {
+		t.Errorf("stepping back from the end shows %d moves at %v", s.shown, s.at)
+	}
+
+	if len(s.record.Moves) != 3 || s.record.Duration() != 0 {
+		t.Errorf("playback changed the record: %+v", s.record.Moves)
+	}
+}
+
+func TestTrackSaves(t *testing.T) {
+	record(t)
+
+	config.replays = t.TempDir()
+	defer func() { config.replays, tracker = "", replay.Tracker{} }()
+
+	SetRules(game.Classic)
+	tracker = replay.Tracker{Rules: game.Classic, Names: [2]string{"maria", "AI (greedy)"}}
+
+	local := game.New(game.Classic, game.X)
+
+	ApplyState(0, 0, local.Cells(), local.Turn(), local.Active())
+	Track(game.None, false)
+
+	for _, i := range []int{0, 3, 1, 4, 2} {
+		local.Play(local.Turn(), i)
+
+		ApplyState(0, 0, local.Cells(), local.Turn(), local.Active())
+		Track(local.Winner(), false)
+	}
+
+	var r *replay.Record
+	var path string
+	var err error
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if r, path, err = OpenReplay(""); err == nil {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Moves) != 5 || r.Winner != game.X || r.Result() != "maria wins" {
+		t.Errorf("saved %+v", r)
+	}
+
+	s := &ReplayScene{record: r, path: path}
+	s.Choose(replayExport)
+
+	text, _, err := OpenReplay(strings.TrimSuffix(path, replay.Ext) + replay.TextExt)
+
+	if err != nil || !reflect.DeepEqual(text.Moves, r.Moves) {
+		t.Errorf("exported %+v, %v, want the moves saved (%s)", text, err, s.message)
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"cardgame/clock"
+	"cardgame/game"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Scene is one screen of the client. Enter runs when the scene is pushed and
+// Exit when it is popped. Only the scene on top of the stack gets events,
+// updates and draws, the ones below it wait until it is gone. Event gets the
+// action the event is bound to, ActionNone if there is none.
+type Scene interface {
+	Enter()
+	Exit()
+	Update()
+	Draw()
+	Event(event sdl.Event, action Action)
+}
+
+type SceneStack struct {
+	scenes []Scene
+}
+
+var scenes SceneStack
+
+// Top returns the current scene, nil if the stack is empty.
+func (s *SceneStack) Top() Scene {
+	if len(s.scenes) == 0 {
+		return nil
+	}
+
+	return s.scenes[len(s.scenes)-1]
+}
+
+func (s *SceneStack) Push(scene Scene) {
+	s.scenes = append(s.scenes, scene)
+	scene.Enter()
+}
+
+func (s *SceneStack) Pop() {
+	top := s.Top()
+
+	if top == nil {
+		return
+	}
+
+	s.scenes = s.scenes[:len(s.scenes)-1]
+	top.Exit()
+}
+
+func (s *SceneStack) Replace(scene Scene) {
+	s.Pop()
+	s.Push(scene)
+}
+
+// Reset pops every scene and leaves scene as the only one.
+func (s *SceneStack) Reset(scene Scene) {
+	for len(s.scenes) > 0 {
+		s.Pop()
+	}
+
+	s.Push(scene)
+}
+
+func (s *SceneStack) Update() {
+	if top := s.Top(); top != nil {
+		top.Update()
+	}
+}
+
+func (s *SceneStack) Draw() {
+	if top := s.Top(); top != nil {
+		top.Draw()
+	}
+}
+
+func (s *SceneStack) Event(event sdl.Event, action Action) {
+	if top := s.Top(); top != nil {
+		top.Event(event, action)
+	}
+}
+
+// clicked returns where event pressed a mouse button, so that actions bound
+// to the mouse go to what is under it rather than to the focus.
+func clicked(event sdl.Event) (vec2, bool) {
+	t, ok := event.(*sdl.MouseButtonEvent)
+
+	if !ok || t.Type != sdl.MOUSEBUTTONDOWN {
+		return vec2{}, false
+	}
+
+	return vec2{float32(t.X), float32(t.Y)}, true
+}
+
+// ToMenu leaves the current game and goes back to the title screen.
+func ToMenu() {
+	Leave()
+	scenes.Reset(&MainMenuScene{})
+}
+
+// The entries of the main menu, in the order they are laid out.
+const (
+	menuOnline = iota
+	menuOffline
+	menuDifficulty
+	menuReplays
+	menuControls
+	menuQuit
+)
+
+// The first menu entry and the height of every entry.
+const menuTop, menuPitch = 44, 20
+
+// MainMenuScene is the title screen. It starts online and offline games,
+// picks the AI difficulty and opens the replays and the controls.
+type MainMenuScene struct {
+	buttons []Button
+	focus   Focus
+	message string
+}
+
+func (s *MainMenuScene) Enter() {
+	size := vec2{96, 16}
+
+	s.buttons = nil
+	s.focus = Focus{-1}
+
+	for i := 0; i <= menuQuit; i++ {
+		pos := vec2{W/2 - size.x/2, menuTop + float32(i)*menuPitch}
+		s.buttons = append(s.buttons, &SimpleButton{ButtonData: ButtonData{pos, size}})
+	}
+}
+
+func (s *MainMenuScene) Exit() {}
+
+func (s *MainMenuScene) Update() {}
+
+// Label returns the text of menu entry i.
+func (s *MainMenuScene) Label(i int) string {
+	switch i {
+	case menuOnline:
+		return "Play online"
+	case menuOffline:
+		return "Play the AI"
+	case menuDifficulty:
+		return "AI: " + config.difficulty
+	case menuReplays:
+		return "Replays"
+	case menuControls:
+		return "Controls"
+	default:
+		return "Quit"
+	}
+}
+
+func (s *MainMenuScene) Choose(i int) {
+	switch i {
+	case menuOnline:
+		if err := StartOnline(); err != nil {
+			fmt.Println("[CLIENT] Error connecting:", err.Error())
+			s.message = "Could not reach the server"
+			return
+		}
+
+		scenes.Replace(&LobbyScene{})
+	case menuOffline:
+		if err := StartOffline(); err != nil {
+			fmt.Println("[CLIENT] Error starting offline game:", err.Error())
+			s.message = err.Error()
+			return
+		}
+
+		scenes.Replace(&GameScene{})
+	case menuDifficulty:
+		difficulty, _ := game.ParseDifficulty(config.difficulty)
+		config.difficulty = ((difficulty + 1) % (game.Perfect + 1)).String()
+	case menuReplays:
+		record, path, err := OpenReplay(config.replay)
+
+		if err != nil {
+			fmt.Println("[CLIENT] Error opening replay:", err.Error())
+			s.message = err.Error()
+			return
+		}
+
+		scenes.Replace(&ReplayScene{record: record, path: path})
+	case menuControls:
+		scenes.Push(&ControlsScene{})
+	case menuQuit:
+		engine.run = false
+	}
+}
+
+func (s *MainMenuScene) Draw() {
+	for i := range s.buttons {
+		s.buttons[i].Draw()
+	}
+
+	renderer.Bind(fontTexture)
+
+	title := TextStyle{size: 16, color: vec4{0, 1, 0, 1}, align: AlignCenter}
+	DrawString(vec2{W / 2, 24}, "GOTACTOE", title)
+
+	center := defaultStyle
+	center.align = AlignCenter
+
+	for i := range s.buttons {
+		DrawString(vec2{W / 2, menuTop + float32(i)*menuPitch + 4}, s.Label(i), center)
+	}
+
+	center.color = vec4{1, 0, 0, 1}
+	DrawTextBox(vec2{0, H - 20}, vec2{W, 16}, s.message, center)
+
+	renderer.Bind(defaultTexture)
+	player.Draw()
+}
+
+func (s *MainMenuScene) Event(event sdl.Event, action Action) {
+	if _, ok := event.(*sdl.MouseMotionEvent); ok {
+		s.focus.Set(CheckButtonPress(player.pos, s.buttons), s.buttons)
+	}
+
+	switch action {
+	case ActionUp:
+		s.focus.Set(clamp(s.focus.cell-1, 0, menuQuit), s.buttons)
+	case ActionDown:
+		s.focus.Set(clamp(s.focus.cell+1, 0, menuQuit), s.buttons)
+	case ActionPlaceMark:
+		if spot, ok := clicked(event); ok {
+			s.Choose(CheckButtonPress(spot, s.buttons))
+		} else {
+			s.Choose(s.focus.cell)
+		}
+	case ActionQuit:
+		engine.run = false
+	}
+}
+
+// WaitingScene covers the game with the pulsing square until the match is
+// ready, either for the first time or again after a reconnect, along with
+// the join code of the room to pass on to a friend. Losing the seat for good
+// goes back to the lobby.
+type WaitingScene struct {
+	alpha float32
+	pulse *Tween
+}
+
+// pulseDuration is how long the waiting square takes to fade in, and again
+// to fade out, in seconds.
+const pulseDuration = 1.6
+
+func (s *WaitingScene) Enter() {
+	s.pulse = TweenFloat(&s.alpha, 0, 1, pulseDuration, EaseInOutSine,
+		clock.TWO_WAY|clock.LOOP)
+	animations.Add(s.pulse)
+}
+
+func (s *WaitingScene) Exit() {
+	animations.Remove(s.pulse)
+}
+
+func (s *WaitingScene) Update() {
+	if ready {
+		scenes.Pop()
+	} else if !offline && !reconnecting && room == "" {
+		scenes.Reset(&LobbyScene{})
+	}
+}
+
+func (s *WaitingScene) Draw() {
+	renderer.Bind(defaultTexture)
+	DrawWaiting(s.alpha)
+
+	if !offline && room != "" {
+		renderer.Bind(fontTexture)
+
+		center := defaultStyle
+		center.align = AlignCenter
+
+		DrawString(vec2{W / 2, H/2 + 24}, "Room "+room, center)
+	}
+}
+
+func (s *WaitingScene) Event(event sdl.Event, action Action) {
+	if action == ActionQuit {
+		ToMenu()
+	}
+}
+
+// GameScene is the board being played. It pushes the waiting and game over
+// scenes on top of itself when there is nothing to play, and the takeback
+// scene when the opponent asks for a move back.
+type GameScene struct {
+	focus Focus
+}
+
+func (s *GameScene) Enter() {
+	s.focus = Focus{-1}
+}
+
+func (s *GameScene) Exit() {}
+
+func (s *GameScene) Update() {
+	if !ready {
+		scenes.Push(&WaitingScene{})
+		return
+	}
+
+	if winner := RoundWinner(); winner != game.None {
+		scenes.Push(&GameOverScene{winner: winner, onTime: turnClocks.flagged != game.None})
+		return
+	}
+
+	if offered {
+		scenes.Push(&TakebackScene{})
+	}
+}
+
+func (s *GameScene) Draw() {
+	DrawBoard()
+}
+
+func (s *GameScene) Event(event sdl.Event, action Action) {
+	buttons := engine.buttons
+
+	if _, ok := event.(*sdl.MouseMotionEvent); ok {
+		s.focus.Set(CheckButtonPress(player.pos, buttons), buttons)
+	}
+
+	switch action {
+	case ActionUp:
+		s.focus.Move(0, -1, buttons)
+	case ActionDown:
+		s.focus.Move(0, 1, buttons)
+	case ActionLeft:
+		s.focus.Move(-1, 0, buttons)
+	case ActionRight:
+		s.focus.Move(1, 0, buttons)
+	case ActionCell1, ActionCell2, ActionCell3, ActionCell4, ActionCell5,
+		ActionCell6, ActionCell7, ActionCell8, ActionCell9:
+		n := int(action-ActionCell1) + 1
+
+		if s.focus.Numpad(n, buttons) {
+			PlaceMark(s.focus.cell)
+		} else {
+			s.focus.Move((n-1)%3-1, 1-(n-1)/3, buttons)
+		}
+	case ActionPlaceMark:
+		if spot, ok := clicked(event); ok {
+			PlaceMark(CheckButtonPress(spot, buttons))
+		} else {
+			PlaceMark(s.focus.cell)
+		}
+	case ActionUndo:
+		Takeback()
+	case ActionRedo:
+		Redo()
+	case ActionQuit:
+		ToMenu()
+	}
+}
+
+// GameOverScene shows who took the round over the finished board. It goes
+// away by itself when the next round starts.
+type GameOverScene struct {
+	winner game.Side
+	onTime bool
+}
+
+func (s *GameOverScene) Enter() {}
+
+func (s *GameOverScene) Exit() {}
+
+func (s *GameOverScene) Update() {
+	if !ready || RoundWinner() == game.None {
+		scenes.Pop()
+	}
+}
+
+// Banner returns what the round ended in, seen from our side.
+// Spectators are told who won instead.
+func (s *GameOverScene) Banner() string {
+	onTime := ""
+
+	if s.onTime {
+		onTime = " on time"
+	}
+
+	switch {
+	case s.winner == game.Draw:
+		return "Draw"
+	case Spectating():
+		return SideName(s.winner) + " wins" + onTime
+	case s.winner == game.Side(side):
+		return "You win" + onTime + "!"
+	default:
+		return "You lose" + onTime
+	}
+}
+
+func (s *GameOverScene) Draw() {
+	DrawBoard()
+
+	renderer.Bind(defaultTexture)
+	renderer.Draw(getModel(vec2{0, H/2 - 16}, vec2{W, 32}),
+		defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{0, 0, 0, .75})
+
+	renderer.Bind(fontTexture)
+
+	center := defaultStyle
+	center.align = AlignCenter
+
+	DrawString(vec2{W / 2, H/2 - 12}, s.Banner(), center)
+
+	center.color = vec4{.5, .5, .5, 1}
+	DrawString(vec2{W / 2, H/2 + 4}, "Esc for menu", center)
+}
+
+func (s *GameOverScene) Event(event sdl.Event, action Action) {
+	if action == ActionQuit {
+		ToMenu()
+	}
+}
+
+// ControlsScene lists every action with what triggers it. Picking one waits
+// for the next key, mouse button or controller button and binds it in place
+// of the ones the action had on that device. Escape cancels. The bindings are
+// saved to the config file right away.
+type ControlsScene struct {
+	row     int
+	waiting bool
+	message string
+}
+
+// The first row of the controls list and the height of every row.
+const controlsTop, controlsPitch = 12, 8
+
+func (s *ControlsScene) Enter() {}
+
+func (s *ControlsScene) Exit() {}
+
+func (s *ControlsScene) Update() {}
+
+// Action returns the action listed on the focused row.
+func (s *ControlsScene) Action() Action {
+	return ActionPlaceMark + Action(s.row)
+}
+
+func (s *ControlsScene) Draw() {
+	renderer.Bind(fontTexture)
+
+	DrawString(vec2{8, 4}, "Controls", TextStyle{size: 8, color: vec4{0, 1, 0, 1}})
+
+	rows := int(actionCount - ActionPlaceMark)
+
+	for i := 0; i < rows; i++ {
+		style := TextStyle{size: 8, color: vec4{.5, .5, .5, 1}}
+
+		if i == s.row {
+			style.color = vec4{1, 1, 0, 1}
+		}
+
+		action := ActionPlaceMark + Action(i)
+		bindings := config.bindings.Format(action)
+
+		if i == s.row && s.waiting {
+			bindings = "press something..."
+		}
+
+		if len(bindings) > 19 {
+			bindings = bindings[:18] + "~"
+		}
+
+		pos := vec2{8, controlsTop + float32(i)*controlsPitch}
+
+		DrawString(pos, action.String(), style)
+		DrawString(pos.Add(vec2{160, 0}), bindings, style)
+	}
+
+	center := defaultStyle
+	center.align = AlignCenter
+	center.color = vec4{1, 0, 0, 1}
+
+	DrawString(vec2{W / 2, H - 8}, s.message, center)
+
+	renderer.Bind(defaultTexture)
+	player.Draw()
+}
+
+func (s *ControlsScene) Event(event sdl.Event, action Action) {
+	if s.waiting {
+		binding, repeat, ok := EventBinding(event)
+
+		if !ok || repeat {
+			return
+		}
+
+		s.waiting = false
+
+		if binding == Key(sdl.K_ESCAPE) {
+			return
+		}
+
+		old := config.bindings
+		config.bindings.Bind(s.Action(), binding)
+
+		if err := config.Save(bindingKeys(&config.bindings, &old)...); err != nil {
+			fmt.Println("[CLIENT] Error saving config:", err.Error())
+			s.message = "Could not save the config"
+		}
+
+		return
+	}
+
+	rows := int(actionCount - ActionPlaceMark)
+
+	switch action {
+	case ActionUp:
+		s.row = clamp(s.row-1, 0, rows-1)
+	case ActionDown:
+		s.row = clamp(s.row+1, 0, rows-1)
+	case ActionPlaceMark:
+		if spot, ok := clicked(event); ok {
+			y := engine.viewport.ToWorld(spot).y - controlsTop
+			row := int(y) / controlsPitch
+
+			if y < 0 || row >= rows {
+				return
+			}
+
+			s.row = row
+		}
+
+		s.waiting = true
+	case ActionQuit:
+		scenes.Pop()
+	}
+}
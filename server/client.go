@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// How long a new connection has to introduce itself.
+const handshakeTimeout = 5 * time.Second
+
+// maxName is the longest player name a Hello can give, as the client allows.
+const maxName = 32
+
+// How many messages can wait for a client that is slow to read before it is
+// dropped, and how long writing one of them may take.
+const (
+	maxQueued    = 64
+	writeTimeout = 10 * time.Second
+)
+
+// Rules and time control used when a client does not ask for any.
+var defaultRules = game.Classic
+var defaultControl game.TimeControl
+
+// Client is one connection. What is sent to it is queued and written by a
+// goroutine of its own, so that a client that stops reading holds up nobody
+// but itself.
+type Client struct {
+	conn  net.Conn
+	enc   *protocol.Encoder
+	dec   *protocol.Decoder
+	name  string
+	token string
+	rules game.Rules
+
+	control game.TimeControl
+
+	queue  chan protocol.Message
+	closed chan struct{}
+	once   sync.Once
+}
+
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		conn:   conn,
+		enc:    protocol.NewEncoder(conn),
+		dec:    protocol.NewDecoder(conn),
+		queue:  make(chan protocol.Message, maxQueued),
+		closed: make(chan struct{}),
+	}
+
+	go c.write()
+
+	return c
+}
+
+// Handshake waits for the client's Hello. A client presenting a session token
+// goes back to its old seat, any other one is served by the lobby.
+func Handshake(conn net.Conn) {
+	c := NewClient(conn)
+
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+
+	msg, err := c.dec.Decode()
+
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		fmt.Println("[SERVER] Handshake failed with", conn.RemoteAddr(), err.Error())
+		c.Fail(err.Error())
+		return
+	}
+
+	hello, ok := msg.(*protocol.Hello)
+
+	if !ok {
+		c.Fail("expected hello")
+		return
+	}
+
+	if len(hello.Name) > maxName {
+		c.Fail(fmt.Sprintf("name longer than %d characters", maxName))
+		return
+	}
+
+	c.name = hello.Name
+	c.token = hello.Token
+
+	if c.rules, err = HelloRules(hello); err != nil {
+		c.Fail(err.Error())
+		return
+	}
+
+	c.control = defaultControl
+
+	if hello.Base != 0 || hello.Increment != 0 || hello.PerMove != 0 {
+		c.control = game.TimeControl{
+			Base:      time.Duration(hello.Base) * time.Second,
+			Increment: time.Duration(hello.Increment) * time.Second,
+			PerMove:   time.Duration(hello.PerMove) * time.Second,
+		}
+
+		if err := c.control.Validate(); err != nil {
+			c.Fail(err.Error())
+			return
+		}
+	}
+
+	if c.token == "" {
+		lobby.Serve(c)
+		return
+	}
+
+	if seat, ok := sessions.Get(c.token); ok {
+		seat.match.Resume(seat.side, c)
+	} else {
+		c.Fail("unknown session")
+	}
+}
+
+// HelloRules returns the rules a Hello asks for. Without a size it gets the
+// default board, unless it asks for Ultimate, which is always played 3x3, and
+// without a win length it takes a whole row.
+func HelloRules(hello *protocol.Hello) (game.Rules, error) {
+	rules := defaultRules
+	variant := game.Variant(hello.Variant)
+
+	switch {
+	case hello.Size != 0:
+		rules = game.NewRules(int(hello.Size), int(hello.K), variant)
+	case variant != game.Standard:
+		rules = game.Rules{Size: 3, K: 3, Variant: variant}
+	}
+
+	return rules, rules.Validate()
+}
+
+// Send queues m for the client without waiting for it to be written. A
+// client whose queue is full is too far behind and is hung up on.
+func (c *Client) Send(m protocol.Message) {
+	c.push(m)
+}
+
+// Fail reports a fatal error to the client and hangs up once it is written.
+func (c *Client) Fail(text string) {
+	c.push(&protocol.Error{Text: text})
+	c.push(nil)
+}
+
+// push queues m, nil to hang up after the messages queued before it.
+func (c *Client) push(m protocol.Message) {
+	select {
+	case c.queue <- m:
+	default:
+		fmt.Println("[SERVER] Dropping", c.conn.RemoteAddr(), "for not reading")
+		c.Close()
+	}
+}
+
+// write writes out the queue until the client is closed.
+func (c *Client) write() {
+	for {
+		select {
+		case m := <-c.queue:
+			if m == nil {
+				c.Close()
+				return
+			}
+
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+			if err := c.enc.Encode(m); err != nil {
+				fmt.Println("[SERVER] Error sending:", err.Error())
+				c.Close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// Close hangs up right away, dropping whatever is still queued.
+func (c *Client) Close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"testing"
+)
+
+func TestHelloRules(t *testing.T) {
+	ultimate := game.Rules{Size: 3, K: 3, Variant: game.Ultimate}
+
+	tests := []struct {
+		hello protocol.Hello
+		rules game.Rules
+		ok    bool
+	}{
+		{protocol.Hello{}, defaultRules, true},
+		{protocol.Hello{Size: 15, K: 5}, game.Rules{Size: 15, K: 5}, true},
+		{protocol.Hello{Size: 4}, game.Rules{Size: 4, K: 4}, true},
+		{protocol.Hello{Variant: uint8(game.Ultimate)}, ultimate, true},
+		{protocol.Hello{Size: 3, K: 3, Variant: uint8(game.Ultimate)}, ultimate, true},
+		{protocol.Hello{Size: 5, K: 4, Variant: uint8(game.Ultimate)}, game.Rules{}, false},
+		{protocol.Hello{Size: 4, K: 5}, game.Rules{}, false},
+		{protocol.Hello{Variant: 7}, game.Rules{}, false},
+	}
+
+	for _, test := range tests {
+		rules, err := HelloRules(&test.hello)
+
+		if (err == nil) != test.ok || (test.ok && rules != test.rules) {
+			t.Errorf("%+v: got %v, %v, want %v", test.hello, rules, err, test.rules)
+		}
+	}
+}
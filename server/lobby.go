@@ -0,0 +1,271 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Join codes are made of letters and digits that are hard to mix up when
+// read out loud.
+const (
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 6
+)
+
+const maxRoomName = 32
+
+var (
+	errNoRoom     = errors.New("no such room")
+	errRoomFull   = errors.New("room is full")
+	errRoomClosed = errors.New("room is closed")
+)
+
+// Room is a match players can find in the lobby, or join with its code when
+// it is private.
+type Room struct {
+	name    string
+	private bool
+	match   *Match
+}
+
+// wants is what a quick match pairs players on.
+type wants struct {
+	rules   game.Rules
+	control game.TimeControl
+}
+
+// Lobby keeps the rooms by join code and the quick match queue, a room per
+// rules and time control waiting for its second player. Rooms go away as
+// their match stops.
+//
+// The mutex is never held while calling into a match, which takes it from
+// under its own mutex when it stops, and may block sending to a client.
+type Lobby struct {
+	mutex sync.Mutex
+	rooms map[string]*Room
+	queue map[wants]*Room
+}
+
+var lobby = Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+// Serve answers the lobby requests of c until one of them seats it.
+func (l *Lobby) Serve(c *Client) {
+	for {
+		msg, err := c.dec.Decode()
+
+		if err != nil {
+			if protocol.Malformed(err) {
+				c.Send(&protocol.Error{Text: err.Error()})
+				continue
+			}
+
+			c.Close()
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *protocol.ListRooms:
+			c.Send(l.List())
+		case *protocol.CreateRoom:
+			if err := l.Create(c, msg.Name, msg.Private); err != nil {
+				c.Send(&protocol.Error{Text: err.Error()})
+				continue
+			}
+
+			return
+		case *protocol.JoinRoom:
+			if err := l.Join(c, msg.Code, msg.Watch); err != nil {
+				c.Send(&protocol.Error{Text: err.Error()})
+				continue
+			}
+
+			return
+		case *protocol.QuickMatch:
+			l.Quick(c)
+			return
+		default:
+			c.Send(&protocol.Error{Text: "unexpected message"})
+		}
+	}
+}
+
+// List returns the public rooms, full or not.
+func (l *Lobby) List() *protocol.Rooms {
+	l.mutex.Lock()
+
+	public := []*Room{}
+
+	for _, room := range l.rooms {
+		if !room.private {
+			public = append(public, room)
+		}
+	}
+
+	l.mutex.Unlock()
+
+	list := &protocol.Rooms{}
+
+	for _, room := range public {
+		ready := room.match.Ready()
+		players, spectators := room.match.Count()
+
+		list.Rooms = append(list.Rooms, protocol.Room{
+			Code:      room.match.code,
+			Name:      room.name,
+			Size:      ready.Size,
+			K:         ready.K,
+			Variant:   ready.Variant,
+			Base:      ready.Base,
+			Increment: ready.Increment,
+			PerMove:   ready.PerMove,
+
+			Players:    uint8(players),
+			Spectators: uint16(spectators),
+		})
+	}
+
+	sort.Slice(list.Rooms, func(i, j int) bool {
+		return list.Rooms[i].Code < list.Rooms[j].Code
+	})
+
+	return list
+}
+
+// Create opens a room with the rules and time control c asked for and seats
+// c in it.
+func (l *Lobby) Create(c *Client, name string, private bool) error {
+	name = strings.TrimSpace(name)
+
+	if len(name) > maxRoomName {
+		return fmt.Errorf("room name longer than %d characters", maxRoomName)
+	}
+
+	if name == "" && c.name != "" {
+		name = clip(c.name, maxRoomName-len("'s room")) + "'s room"
+	}
+
+	l.mutex.Lock()
+	room := l.open(name, private, c.rules, c.control)
+	l.mutex.Unlock()
+
+	fmt.Println("[SERVER] Opened room", room.match.code, room.name)
+
+	return room.match.Join(c)
+}
+
+// Join seats c in the room with the given code, or lets it watch. The match
+// checks that it is still being played as it seats c, since it can stop
+// right after the lobby found its room.
+func (l *Lobby) Join(c *Client, code string, watch bool) error {
+	l.mutex.Lock()
+	room := l.rooms[strings.ToUpper(strings.TrimSpace(code))]
+	l.mutex.Unlock()
+
+	if room == nil {
+		return errNoRoom
+	}
+
+	if watch {
+		return room.match.Watch(c)
+	}
+
+	return room.match.Join(c)
+}
+
+// Quick seats c in the queue's room for its rules and time control, opening
+// a new one if nobody is waiting. A queued room is taken off the queue by
+// whoever comes to fill it, and when its match stops before that one sits
+// down, the search starts over.
+func (l *Lobby) Quick(c *Client) {
+	key := wants{c.rules, c.control}
+
+	for {
+		l.mutex.Lock()
+
+		room := l.queue[key]
+
+		if room == nil {
+			room = l.open("Quick match", true, c.rules, c.control)
+			l.queue[key] = room
+		} else {
+			delete(l.queue, key)
+		}
+
+		l.mutex.Unlock()
+
+		if room.match.Join(c) == nil {
+			return
+		}
+	}
+}
+
+// open adds a room under a fresh code.
+func (l *Lobby) open(name string, private bool, rules game.Rules,
+	control game.TimeControl) *Room {
+	code := newCode()
+
+	for l.rooms[code] != nil {
+		code = newCode()
+	}
+
+	if name == "" {
+		name = "Room " + code
+	}
+
+	room := &Room{name: name, private: private, match: NewMatch(code, rules, control)}
+	room.match.onStop = func() { l.close(code, room) }
+	l.rooms[code] = room
+
+	return room
+}
+
+// close drops a room whose match stopped, from the queue too if nobody came
+// to fill it.
+func (l *Lobby) close(code string, room *Room) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.rooms[code] == room {
+		delete(l.rooms, code)
+	}
+
+	for key, queued := range l.queue {
+		if queued == room {
+			delete(l.queue, key)
+		}
+	}
+}
+
+// clip cuts s to at most n bytes without splitting a character.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+func newCode() string {
+	raw := make([]byte, codeLength)
+
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+
+	for i := range raw {
+		raw[i] = codeAlphabet[int(raw[i])%len(codeAlphabet)]
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// connect returns a client the way Handshake leaves it, with a channel of
+// everything the server sends it.
+func connect(t *testing.T, name string, rules game.Rules) (*Client, chan protocol.Message) {
+	server, client := net.Pipe()
+
+	c := NewClient(server)
+	c.name, c.rules = name, rules
+
+	received := make(chan protocol.Message, 16)
+
+	go func() {
+		dec := protocol.NewDecoder(client)
+
+		for {
+			msg, err := dec.Decode()
+
+			if err != nil {
+				close(received)
+				return
+			}
+
+			received <- msg
+		}
+	}()
+
+	t.Cleanup(func() { client.Close() })
+
+	return c, received
+}
+
+func seated(t *testing.T, received chan protocol.Message) *protocol.AssignSide {
+	t.Helper()
+
+	select {
+	case msg := <-received:
+		if assign, ok := msg.(*protocol.AssignSide); ok {
+			return assign
+		}
+
+		t.Fatalf("got %#v, want a side", msg)
+	case <-time.After(time.Second):
+		t.Fatal("never seated")
+	}
+
+	return nil
+}
+
+func TestLobbyRooms(t *testing.T) {
+	l := Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+	host, hostMsgs := connect(t, "maria", game.Classic)
+
+	if err := l.Create(host, "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	code := seated(t, hostMsgs).Room
+
+	if list := l.List().Rooms; len(list) != 1 || list[0].Code != code ||
+		list[0].Name != "maria's room" || list[0].Size != 3 {
+		t.Fatalf("listed %#v, want maria's room %s", list, code)
+	}
+
+	if err := l.Create(host, "a name much longer than thirty two bytes", true); err == nil {
+		t.Error("accepted a long room name")
+	}
+
+	guest, guestMsgs := connect(t, "", game.Rules{Size: 15, K: 5})
+
+	if err := l.Join(guest, "nope", false); err != errNoRoom {
+		t.Errorf("joining an unknown code: %v, want %v", err, errNoRoom)
+	}
+
+	if err := l.Join(guest, code, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if side := seated(t, guestMsgs); side.Side != 1 || side.Room != code {
+		t.Errorf("guest got %#v, want side 1 in %s", side, code)
+	}
+
+	if list := l.List().Rooms; len(list) != 1 || list[0].Players != 2 {
+		t.Errorf("a full room is not listed to watch: %#v", list)
+	}
+
+	late, _ := connect(t, "", game.Classic)
+
+	if err := l.Join(late, code, false); err != errRoomFull {
+		t.Errorf("joining a full room: %v, want %v", err, errRoomFull)
+	}
+}
+
+func TestLobbyClosedRoom(t *testing.T) {
+	l := Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+	host, hostMsgs := connect(t, "maria", game.Classic)
+	l.Create(host, "", false)
+	code := seated(t, hostMsgs).Room
+
+	m := l.rooms[code].match
+	m.mutex.Lock()
+	m.stop()
+	m.mutex.Unlock()
+
+	guest, _ := connect(t, "", game.Classic)
+
+	// A match still at hand turns joiners away itself.
+	if err := m.Join(guest); err != errRoomClosed {
+		t.Errorf("joining a stopped match: %v, want %v", err, errRoomClosed)
+	}
+
+	if err := m.Watch(guest); err != errRoomClosed {
+		t.Errorf("watching a stopped match: %v, want %v", err, errRoomClosed)
+	}
+
+	if err := l.Join(guest, code, false); err != errNoRoom {
+		t.Errorf("joining a stopped room: %v, want %v", err, errNoRoom)
+	}
+
+	// A queued room goes when its player leaves before anyone is paired.
+	alone, _ := connect(t, "", game.Classic)
+	l.Quick(alone)
+
+	queued := l.queue[wants{game.Classic, game.TimeControl{}}]
+	queued.match.Leave(game.X, alone)
+
+	if len(l.rooms) != 0 || len(l.queue) != 0 {
+		t.Errorf("rooms %v and queue %v left after every match stopped", l.rooms, l.queue)
+	}
+
+	paired, pairedMsgs := connect(t, "", game.Classic)
+	l.Quick(paired)
+
+	if side := seated(t, pairedMsgs); side.Side != 0 || side.Room == queued.match.code {
+		t.Errorf("quick match after the queue emptied: %#v, want a new room", side)
+	}
+}
+
+func TestLobbyNames(t *testing.T) {
+	l := Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+	long := strings.Repeat("é", maxName/2)
+	host, hostMsgs := connect(t, long, game.Classic)
+	l.Create(host, "", false)
+	seated(t, hostMsgs)
+
+	name := l.List().Rooms[0].Name
+
+	if len(name) > maxRoomName || !utf8.ValidString(name) || !strings.HasSuffix(name, "'s room") {
+		t.Errorf("default name %q is not a valid %d bytes", name, maxRoomName)
+	}
+
+	msgs := dial(t, &protocol.Hello{Name: long + "x"})
+
+	if e := next[*protocol.Error](t, msgs); !strings.Contains(e.Text, "name longer") {
+		t.Errorf("a long hello name: %q", e.Text)
+	}
+}
+
+// next returns the next message of type T, skipping the others.
+func next[T protocol.Message](t *testing.T, received chan protocol.Message) T {
+	t.Helper()
+
+	for {
+		select {
+		case msg, ok := <-received:
+			if !ok {
+				t.Fatal("connection closed")
+			}
+
+			if m, ok := msg.(T); ok {
+				return m
+			}
+		case <-time.After(time.Second):
+			var zero T
+			t.Fatalf("no %T came", zero)
+		}
+	}
+}
+
+func TestSpectator(t *testing.T) {
+	l := Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+	host, hostMsgs := connect(t, "maria", game.Classic)
+	l.Create(host, "watch me", false)
+	code := seated(t, hostMsgs).Room
+
+	early, earlyMsgs := connect(t, "", game.Classic)
+
+	if err := l.Join(early, code, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if side := seated(t, earlyMsgs); side.Side != -1 || side.Token != "" {
+		t.Errorf("spectator got %#v, want side -1 and no token", side)
+	}
+
+	guest, guestMsgs := connect(t, "jose", game.Classic)
+	l.Join(guest, code, false)
+	seated(t, guestMsgs)
+
+	next[*protocol.Ready](t, earlyMsgs)
+	next[*protocol.State](t, earlyMsgs)
+
+	room := l.rooms[code]
+	room.match.Move(game.X, 4)
+
+	if state := next[*protocol.State](t, earlyMsgs); state.Cells[4] != 0 {
+		t.Errorf("spectator did not see the move: %v", state.Cells)
+	}
+
+	late, lateMsgs := connect(t, "", game.Classic)
+	l.Join(late, code, true)
+	seated(t, lateMsgs)
+
+	next[*protocol.Ready](t, lateMsgs)
+
+	if state := next[*protocol.State](t, lateMsgs); state.Cells[4] != 0 {
+		t.Errorf("late spectator got %v, want the board so far", state.Cells)
+	}
+
+	roster := next[*protocol.Roster](t, lateMsgs)
+
+	if roster.Names != [2]string{"maria", "jose"} || roster.Spectators != 2 {
+		t.Errorf("roster %#v, want both names and 2 spectators", roster)
+	}
+
+	if _, spectators := room.match.Count(); spectators != 2 {
+		t.Errorf("%d spectators, want 2", spectators)
+	}
+}
+
+func TestStalledSpectator(t *testing.T) {
+	m := NewMatch("SLOW", game.Classic, game.TimeControl{})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	// Nothing ever reads what is sent to the spectator.
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	m.Watch(NewClient(server))
+
+	// Every round trip sends the spectator three states, the players drain
+	// theirs on the way.
+	for i := 0; i < maxQueued; i++ {
+		m.Move(game.X, 4)
+		m.Move(game.O, 0)
+		m.Takeback(game.X)
+		next[*protocol.Takeback](t, oMsgs)
+		m.Answer(game.O, true)
+		next[*protocol.TakebackReply](t, xMsgs)
+	}
+
+	for deadline := time.Now().Add(time.Second); ; {
+		if _, spectators := m.Count(); spectators == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("the spectator that stopped reading is still watching")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m.Move(game.X, 8)
+
+	// next skips the states of the round trips first.
+	for state := next[*protocol.State](t, oMsgs); state.Cells[8] != 0; {
+		state = next[*protocol.State](t, oMsgs)
+	}
+}
+
+func TestLobbyQuickMatch(t *testing.T) {
+	l := Lobby{rooms: map[string]*Room{}, queue: map[wants]*Room{}}
+
+	a, aMsgs := connect(t, "", game.Classic)
+	b, bMsgs := connect(t, "", game.Rules{Size: 4, K: 3})
+	c, cMsgs := connect(t, "", game.Classic)
+
+	l.Quick(a)
+	l.Quick(b)
+	l.Quick(c)
+
+	first, other, second := seated(t, aMsgs), seated(t, bMsgs), seated(t, cMsgs)
+
+	if first.Room != second.Room || second.Side != 1 {
+		t.Errorf("same rules did not pair: %#v and %#v", first, second)
+	}
+
+	if other.Room == first.Room {
+		t.Error("paired players that asked for different rules")
+	}
+
+	if list := l.List().Rooms; len(list) != 0 {
+		t.Errorf("quick match rooms are listed: %#v", list)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cardgame/game"
+	"flag"
+	"fmt"
+	"net"
+)
+
+const (
+	serverProtocol = "tcp"
+	serverHost     = "127.0.0.1"
+	serverPort     = "8080"
+)
+
+func main() {
+	protocol := flag.String("protocol", serverProtocol, "network to listen on")
+	host := flag.String("host", serverHost, "address to listen on")
+	port := flag.String("port", serverPort, "port to listen on")
+	size := flag.Int("size", game.Classic.Size, "default board size")
+	k := flag.Int("k", game.Classic.K, "default number of marks in a row to win, 0 for the board size")
+	variant := flag.String("variant", game.Standard.String(), "default variant")
+	control := flag.String("time", "none",
+		"default time control, like 300, 180+2 or 10/move")
+
+	flag.Parse()
+
+	v, err := game.ParseVariant(*variant)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defaultRules = game.NewRules(*size, *k, v)
+
+	if err = defaultRules.Validate(); err != nil {
+		panic(err)
+	}
+
+	if defaultControl, err = game.ParseTimeControl(*control); err != nil {
+		panic(err)
+	}
+
+	listener, err := net.Listen(*protocol, *host+":"+*port)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer listener.Close()
+
+	fmt.Println("[SERVER] Listening on", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			fmt.Println("[SERVER] Error accepting:", err.Error())
+			continue
+		}
+
+		go Handshake(conn)
+	}
+}
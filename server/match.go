@@ -0,0 +1,621 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How long a finished round stays on screen before the board is cleared.
+const roundDelay = 2 * time.Second
+
+// How long a seat is kept for a player whose connection dropped.
+const resumeTimeout = 30 * time.Second
+
+// Match pairs two clients in a lobby room and owns the authoritative board. Side 0 is always
+// the first client to join, side 1 the second. A player that drops keeps its
+// seat for resumeTimeout, during which players[side] is nil.
+//
+// Under a time control the clock of the side to move runs from the start of
+// each round, dropped or not, and a side that runs out loses the round.
+//
+// Spectators get everything the players get but can not move. They have no
+// seat to keep, one that drops is gone.
+//
+// A player can ask to take back its last move. The match holds on to the
+// request until the opponent answers it, moves instead, which turns it down,
+// or the round ends.
+type Match struct {
+	mutex   sync.Mutex
+	code    string
+	players [2]*Client
+	names   [2]string
+	tokens  [2]string
+	absent  [2]*time.Timer
+	joined  int
+	resume  time.Duration // resumeTimeout, shorter in tests
+
+	// readers are closed as the Listen goroutine of each seat returns.
+	readers [2]chan struct{}
+
+	rules            game.Rules
+	board            game.Game
+	score            [2]uint8
+	first            game.Side
+	started, stopped bool
+
+	spectators map[*Client]bool
+
+	control game.TimeControl
+	clocks  game.Clocks
+	flag    *time.Timer
+	flagged game.Side
+
+	// banks holds the clocks as they were before each move of the round, so
+	// that a takeback can put them back.
+	banks [][2]time.Duration
+
+	// asking is the side waiting for an answer to a takeback, None when
+	// nobody is.
+	asking game.Side
+
+	// onStop, when set, is called with the mutex held once the match stops.
+	onStop func()
+}
+
+func NewMatch(code string, rules game.Rules, control game.TimeControl) *Match {
+	m := &Match{code: code, rules: rules, control: control, resume: resumeTimeout,
+		spectators: map[*Client]bool{}}
+	m.Reset()
+
+	return m
+}
+
+// Join seats c on the next free side, tells the client which one it got and
+// starts the match once both sides are taken. A match that is full or already
+// over turns c away.
+func (m *Match) Join(c *Client) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped {
+		return errRoomClosed
+	}
+
+	if m.joined == len(m.players) {
+		return errRoomFull
+	}
+
+	side := game.Side(m.joined)
+	m.players[side] = c
+	m.names[side] = c.name
+	m.tokens[side] = sessions.New(m, side)
+	m.joined++
+
+	fmt.Println("[SERVER] Player", side, "joined room", m.code, "from", c.conn.RemoteAddr())
+
+	c.Send(&protocol.AssignSide{Side: int8(side), Token: m.tokens[side], Room: m.code})
+
+	m.listen(side, c)
+
+	m.send(m.Roster())
+
+	if m.joined == len(m.players) {
+		m.started = true
+		m.startClock()
+
+		m.send(m.Ready())
+		m.broadcast()
+	}
+
+	return nil
+}
+
+// Watch adds c as a spectator. A match under way sends it the rules and the
+// board right away, one still waiting for its second player does so when it
+// starts.
+func (m *Match) Watch(c *Client) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped {
+		return errRoomClosed
+	}
+
+	m.spectators[c] = true
+
+	fmt.Println("[SERVER] Spectator joined room", m.code, "from", c.conn.RemoteAddr())
+
+	c.Send(&protocol.AssignSide{Side: int8(game.None), Room: m.code})
+
+	if m.started {
+		c.Send(m.Ready())
+		c.Send(m.State())
+	}
+
+	m.send(m.Roster())
+
+	go m.Spectate(c)
+
+	return nil
+}
+
+// Spectate reads from a spectator's connection until it drops, turning down
+// whatever it asks for.
+func (m *Match) Spectate(c *Client) {
+	for {
+		msg, err := c.dec.Decode()
+
+		if err != nil {
+			if protocol.Malformed(err) {
+				c.Send(&protocol.Error{Text: err.Error()})
+				continue
+			}
+
+			m.Unwatch(c)
+			return
+		}
+
+		switch msg.(type) {
+		case *protocol.Move, *protocol.Takeback, *protocol.TakebackReply:
+			c.Send(&protocol.Error{Text: "spectators can not move"})
+		default:
+			c.Send(&protocol.Error{Text: "unexpected message"})
+		}
+	}
+}
+
+func (m *Match) Unwatch(c *Client) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.spectators[c] {
+		return
+	}
+
+	delete(m.spectators, c)
+	c.Close()
+
+	if !m.stopped {
+		m.send(m.Roster())
+	}
+}
+
+// Resume gives a reconnecting client its seat back and sends it everything it
+// needs to redraw the game. A connection still holding the seat is closed
+// first, and c only sits down once its reader is gone, so that nothing the
+// old connection sent is played for c.
+func (m *Match) Resume(side game.Side, c *Client) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for m.players[side] != nil {
+		old, reader := m.players[side], m.readers[side]
+		m.players[side] = nil
+		old.Close()
+
+		m.mutex.Unlock()
+		<-reader
+		m.mutex.Lock()
+	}
+
+	if m.stopped {
+		c.Fail("session expired")
+		return
+	}
+
+	if m.absent[side] != nil {
+		m.absent[side].Stop()
+		m.absent[side] = nil
+	}
+
+	m.players[side] = c
+
+	fmt.Println("[SERVER] Player", side, "resumed from", c.conn.RemoteAddr())
+
+	c.Send(&protocol.AssignSide{Side: int8(side), Token: m.tokens[side], Room: m.code})
+
+	if m.started {
+		c.Send(m.Ready())
+		c.Send(m.State())
+	}
+
+	c.Send(m.Roster())
+
+	m.listen(side, c)
+}
+
+// Leave frees the seat of a dropped client. A started match waits for it to
+// come back, a match that never started is simply dropped.
+func (m *Match) Leave(side game.Side, c *Client) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.players[side] != c {
+		return
+	}
+
+	m.players[side] = nil
+	c.Close()
+
+	if !m.started {
+		m.stop()
+		return
+	}
+
+	m.absent[side] = time.AfterFunc(m.resume, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		if m.players[side] == nil {
+			fmt.Println("[SERVER] Player", side, "did not come back")
+			m.stop()
+		}
+	})
+}
+
+func (m *Match) Full() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.joined == len(m.players)
+}
+
+// Count returns how many players joined and how many spectators are watching.
+func (m *Match) Count() (int, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.joined, len(m.spectators)
+}
+
+func (m *Match) Stopped() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.stopped
+}
+
+// Reset clears the board and the clocks, starting the first clock if the
+// match is under way.
+func (m *Match) Reset() {
+	m.board = game.New(m.rules, m.first)
+	m.clocks = game.NewClocks(m.control)
+	m.banks = nil
+	m.flagged = game.None
+	m.asking = game.None
+
+	if m.started {
+		m.startClock()
+	}
+}
+
+// listen starts the reader of side's seat.
+func (m *Match) listen(side game.Side, c *Client) {
+	reader := make(chan struct{})
+	m.readers[side] = reader
+
+	go func() {
+		defer close(reader)
+		m.Listen(side, c)
+	}()
+}
+
+// Listen reads messages from one connection until it drops.
+func (m *Match) Listen(side game.Side, c *Client) {
+	for {
+		msg, err := c.dec.Decode()
+
+		if err != nil {
+			if protocol.Malformed(err) {
+				c.Send(&protocol.Error{Text: err.Error()})
+				continue
+			}
+
+			fmt.Println("[SERVER] Player", side, "disconnected:", err.Error())
+			m.Leave(side, c)
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *protocol.Move:
+			m.Move(side, int(msg.Cell))
+		case *protocol.Takeback:
+			m.Takeback(side)
+		case *protocol.TakebackReply:
+			m.Answer(side, msg.Accept)
+		default:
+			c.Send(&protocol.Error{Text: "unexpected message"})
+		}
+	}
+}
+
+// Move plays side's mark on cell i. An empty seat can only be one that is
+// being resumed, whose old reader is on its way out, so its moves are dropped.
+func (m *Match) Move(side game.Side, i int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped || !m.started || m.flagged != game.None || m.players[side] == nil {
+		return
+	}
+
+	now := time.Now()
+
+	if m.clocks.Running() == side && m.clocks.Flagged(side, now) {
+		m.timeout(side)
+		return
+	}
+
+	banks := m.clocks.Banks()
+
+	if err := m.board.Play(side, i); err != nil {
+		fmt.Println("[SERVER] Rejected move", i, "from player", side, err.Error())
+		m.players[side].Send(&protocol.Error{Text: err.Error()})
+		return
+	}
+
+	m.banks = append(m.banks, banks)
+
+	if m.asking == side.Other() {
+		if asker := m.players[m.asking]; asker != nil {
+			asker.Send(&protocol.TakebackReply{Accept: false})
+		}
+	}
+
+	m.asking = game.None
+
+	winner := m.board.Winner()
+
+	if winner == game.X || winner == game.O {
+		m.score[winner]++
+	}
+
+	if winner == game.None {
+		m.startClock()
+	} else {
+		m.stopClock(now)
+	}
+
+	m.broadcast()
+
+	if winner != game.None {
+		m.send(&protocol.GameOver{Winner: int8(winner), Score: m.score})
+
+		time.AfterFunc(roundDelay, m.NextRound)
+	}
+}
+
+// Takeback passes on side's request to take back its last move to the
+// opponent. It is turned down when the round is over, side has not moved
+// yet, a request is already waiting or the opponent is away.
+func (m *Match) Takeback(side game.Side) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.players[side] == nil {
+		return
+	}
+
+	refuse := func(reason string) {
+		m.players[side].Send(&protocol.Error{Text: reason})
+	}
+
+	switch {
+	case m.stopped || !m.started || m.flagged != game.None || m.board.Winner() != game.None:
+		refuse("no game to take a move back from")
+	case m.asking != game.None:
+		refuse("a takeback is already waiting for an answer")
+	case game.Takeback(m.board.Clone(), side) != nil:
+		refuse(game.ErrNoUndo.Error())
+	case m.players[side.Other()] == nil:
+		refuse("opponent is away")
+	default:
+		fmt.Println("[SERVER] Player", side, "asked for a takeback")
+
+		m.asking = side
+		m.players[side.Other()].Send(&protocol.Takeback{})
+	}
+}
+
+// Answer settles the takeback the opponent of side asked for. On accept the
+// moves go, the clocks are put back to what they were before the first of
+// them and the clock goes back to the side that asked.
+func (m *Match) Answer(side game.Side, accept bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	asker := side.Other()
+
+	if m.players[side] == nil {
+		return
+	}
+
+	if m.asking != asker {
+		m.players[side].Send(&protocol.Error{Text: "no takeback to answer"})
+		return
+	}
+
+	m.asking = game.None
+
+	fmt.Println("[SERVER] Player", side, "answered the takeback:", accept)
+
+	if p := m.players[asker]; p != nil {
+		p.Send(&protocol.TakebackReply{Accept: accept})
+	}
+
+	if !accept {
+		return
+	}
+
+	if err := game.Takeback(m.board, asker); err != nil {
+		fmt.Println("[SERVER] Error taking back:", err.Error())
+		return
+	}
+
+	ply := len(m.board.History())
+
+	m.clocks.Restore(m.banks[ply])
+	m.banks = m.banks[:ply]
+	m.startClock()
+	m.broadcast()
+}
+
+// startClock hands the clock to the side to move and arms the flag for when
+// its time runs out.
+func (m *Match) startClock() {
+	if !m.control.Timed() {
+		return
+	}
+
+	now, turn := time.Now(), m.board.Turn()
+
+	m.clocks.Start(turn, now)
+
+	if m.flag != nil {
+		m.flag.Stop()
+	}
+
+	m.flag = time.AfterFunc(m.clocks.Left(turn, now), func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		if !m.stopped && m.flagged == game.None && m.clocks.Running() == turn &&
+			m.clocks.Flagged(turn, time.Now()) {
+			m.timeout(turn)
+		}
+	})
+}
+
+func (m *Match) stopClock(now time.Time) {
+	m.clocks.Stop(now)
+
+	if m.flag != nil {
+		m.flag.Stop()
+		m.flag = nil
+	}
+}
+
+// timeout gives the round to the other side of one that ran out of time.
+func (m *Match) timeout(side game.Side) {
+	winner := side.Other()
+
+	fmt.Println("[SERVER] Player", side, "ran out of time")
+
+	m.stopClock(time.Now())
+	m.flagged = side
+	m.asking = game.None
+	m.score[winner]++
+
+	m.broadcast()
+	m.send(&protocol.GameOver{Winner: int8(winner), Score: m.score,
+		Reason: protocol.ReasonTime})
+
+	time.AfterFunc(roundDelay, m.NextRound)
+}
+
+// NextRound clears the board and hands the first move to the other side.
+func (m *Match) NextRound() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped {
+		return
+	}
+
+	m.first = m.first.Other()
+	m.Reset()
+	m.broadcast()
+}
+
+func (m *Match) stop() {
+	if m.stopped {
+		return
+	}
+
+	m.stopped = true
+
+	m.stopClock(time.Now())
+
+	for side, p := range m.players {
+		if p != nil {
+			p.Close()
+		}
+
+		if m.absent[side] != nil {
+			m.absent[side].Stop()
+		}
+
+		sessions.Remove(m.tokens[side])
+	}
+
+	for c := range m.spectators {
+		c.Close()
+	}
+
+	if m.onStop != nil {
+		m.onStop()
+	}
+}
+
+func (m *Match) Ready() *protocol.Ready {
+	return &protocol.Ready{
+		Size:    uint8(m.rules.Size),
+		K:       uint8(m.rules.K),
+		Variant: uint8(m.rules.Variant),
+
+		Base:      uint16(m.control.Base / time.Second),
+		Increment: uint16(m.control.Increment / time.Second),
+		PerMove:   uint16(m.control.PerMove / time.Second),
+	}
+}
+
+func (m *Match) Roster() *protocol.Roster {
+	return &protocol.Roster{Names: m.names, Spectators: uint16(len(m.spectators))}
+}
+
+func (m *Match) State() *protocol.State {
+	state := &protocol.State{
+		Score:  m.score,
+		Turn:   int8(m.board.Turn()),
+		Winner: int8(m.board.Winner()),
+		Active: int8(m.board.Active()),
+	}
+
+	if m.flagged != game.None {
+		state.Winner = int8(m.flagged.Other())
+		state.Reason = protocol.ReasonTime
+	}
+
+	now := time.Now()
+
+	for side := range state.Clock {
+		state.Clock[side] = uint32(m.clocks.Left(game.Side(side), now) / time.Millisecond)
+	}
+
+	for _, v := range m.board.Cells() {
+		state.Cells = append(state.Cells, int8(v))
+	}
+
+	return state
+}
+
+func (m *Match) broadcast() {
+	m.send(m.State())
+}
+
+// send writes msg to every player that is currently connected and to every
+// spectator.
+func (m *Match) send(msg protocol.Message) {
+	for _, p := range m.players {
+		if p != nil {
+			p.Send(msg)
+		}
+	}
+
+	for c := range m.spectators {
+		c.Send(msg)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"testing"
+	"time"
+)
+
+func TestTakeback(t *testing.T) {
+	m := NewMatch("TAKE", game.Classic, game.TimeControl{})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	m.Takeback(game.X)
+
+	if e := next[*protocol.Error](t, xMsgs); e.Text != game.ErrNoUndo.Error() {
+		t.Errorf("takeback before moving: %q", e.Text)
+	}
+
+	m.Move(game.X, 4)
+	m.Move(game.O, 0)
+	m.Takeback(game.X)
+
+	next[*protocol.Takeback](t, oMsgs)
+
+	m.Takeback(game.X)
+
+	if e := next[*protocol.Error](t, xMsgs); e.Text == "" {
+		t.Error("asked twice without an error")
+	}
+
+	m.Answer(game.O, false)
+
+	if reply := next[*protocol.TakebackReply](t, xMsgs); reply.Accept {
+		t.Error("declined takeback came back accepted")
+	}
+
+	if m.board.Cell(0) != game.O {
+		t.Errorf("declined takeback changed the board: %v", m.board.Cells())
+	}
+
+	m.Takeback(game.X)
+	next[*protocol.Takeback](t, oMsgs)
+	m.Answer(game.O, true)
+
+	if reply := next[*protocol.TakebackReply](t, xMsgs); !reply.Accept {
+		t.Error("accepted takeback came back declined")
+	}
+
+	for {
+		state := next[*protocol.State](t, xMsgs)
+
+		if state.Cells[4] == -1 && state.Cells[0] == -1 {
+			if game.Side(state.Turn) != game.X {
+				t.Errorf("turn %d after the takeback, want X", state.Turn)
+			}
+
+			break
+		}
+	}
+
+	// Moving instead of answering turns the request down.
+	m.Move(game.X, 4)
+	m.Takeback(game.X)
+	next[*protocol.Takeback](t, oMsgs)
+	m.Move(game.O, 8)
+
+	if reply := next[*protocol.TakebackReply](t, xMsgs); reply.Accept {
+		t.Error("moving accepted the takeback")
+	}
+
+	m.Answer(game.O, true)
+
+	if e := next[*protocol.Error](t, oMsgs); e.Text != "no takeback to answer" {
+		t.Errorf("answering a settled takeback: %q", e.Text)
+	}
+
+	// Taking moves back puts the clocks back, increments included.
+	timed := NewMatch("TIME", game.Classic, game.TimeControl{Base: time.Minute,
+		Increment: 10 * time.Second})
+	defer func() {
+		timed.mutex.Lock()
+		timed.stop()
+		timed.mutex.Unlock()
+	}()
+
+	x, xMsgs = connect(t, "maria", game.Classic)
+	o, oMsgs = connect(t, "jose", game.Classic)
+
+	timed.Join(x)
+	timed.Join(o)
+
+	for i := 0; i < 3; i++ {
+		timed.Move(game.X, 4)
+		timed.Move(game.O, 0)
+		timed.Takeback(game.X)
+		next[*protocol.Takeback](t, oMsgs)
+		timed.Answer(game.O, true)
+		next[*protocol.TakebackReply](t, xMsgs)
+	}
+
+	timed.mutex.Lock()
+	defer timed.mutex.Unlock()
+
+	// Every move was taken back, so neither side kept the increments it
+	// earned with them.
+	want, now := time.Minute, time.Now()
+
+	if len(timed.banks) != 0 {
+		t.Errorf("%d clock banks kept for an empty board", len(timed.banks))
+	}
+
+	for _, side := range []game.Side{game.X, game.O} {
+		if left := timed.clocks.Left(side, now); left > want || left < want-time.Second {
+			t.Errorf("side %d has %v after three takebacks, want %v", side, left, want)
+		}
+	}
+}
+
+func TestMatchPairing(t *testing.T) {
+	m := NewMatch("PAIR", game.Classic, game.TimeControl{})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+
+	if m.Full() || m.started {
+		t.Error("started with one player")
+	}
+
+	m.Join(o)
+
+	for side, msgs := range []chan protocol.Message{xMsgs, oMsgs} {
+		if assign := seated(t, msgs); assign.Side != int8(side) || assign.Token == "" {
+			t.Errorf("player %d got %#v", side, assign)
+		}
+
+		next[*protocol.Ready](t, msgs)
+
+		if state := next[*protocol.State](t, msgs); state.Turn != 0 || state.Winner != -1 {
+			t.Errorf("player %d started with %#v", side, state)
+		}
+	}
+}
+
+func TestMatchMoves(t *testing.T) {
+	m := NewMatch("MOVE", game.Classic, game.TimeControl{})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	m.Move(game.O, 0)
+
+	if e := next[*protocol.Error](t, oMsgs); e.Text != game.ErrNotYourTurn.Error() {
+		t.Errorf("moving out of turn: %q", e.Text)
+	}
+
+	m.Move(game.X, 4)
+	m.Move(game.O, 4)
+
+	if e := next[*protocol.Error](t, oMsgs); e.Text != game.ErrOccupied.Error() {
+		t.Errorf("moving on a taken cell: %q", e.Text)
+	}
+
+	m.Move(game.O, 9)
+
+	if e := next[*protocol.Error](t, oMsgs); e.Text != game.ErrOutOfRange.Error() {
+		t.Errorf("moving off the board: %q", e.Text)
+	}
+
+	if cells := m.board.Cells(); cells[0] != game.None || cells[4] != game.X {
+		t.Errorf("refused moves changed the board: %v", cells)
+	}
+
+	for _, move := range []struct {
+		side game.Side
+		cell int
+	}{{game.O, 0}, {game.X, 3}, {game.O, 1}, {game.X, 5}} {
+		m.Move(move.side, move.cell)
+	}
+
+	over := next[*protocol.GameOver](t, xMsgs)
+
+	if over.Winner != int8(game.X) || over.Score != [2]uint8{1, 0} {
+		t.Errorf("round over %#v, want X scoring 1-0", over)
+	}
+
+	m.Move(game.O, 2)
+
+	if cells := m.board.Cells(); cells[2] != game.None {
+		t.Errorf("played on a finished board: %v", cells)
+	}
+}
+
+func TestMatchTimeout(t *testing.T) {
+	m := NewMatch("FLAG", game.Classic, game.TimeControl{Base: 50 * time.Millisecond})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	// X never moves, so its flag falls on its own.
+	over := next[*protocol.GameOver](t, oMsgs)
+
+	if over.Winner != int8(game.O) || over.Score != [2]uint8{0, 1} ||
+		over.Reason != protocol.ReasonTime {
+		t.Errorf("round over %#v, want O scoring 0-1 on time", over)
+	}
+
+	for state := next[*protocol.State](t, xMsgs); state.Reason != protocol.ReasonTime; {
+		state = next[*protocol.State](t, xMsgs)
+	}
+
+	m.Move(game.X, 4)
+
+	if cells := m.board.Cells(); cells[4] != game.None {
+		t.Errorf("played after running out of time: %v", cells)
+	}
+}
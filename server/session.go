@@ -0,0 +1,55 @@
+package main
+
+import (
+	"cardgame/game"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Seat is where a session token puts its holder back after a reconnect.
+type Seat struct {
+	match *Match
+	side  game.Side
+}
+
+type Sessions struct {
+	mutex sync.Mutex
+	seats map[string]Seat
+}
+
+var sessions = Sessions{seats: map[string]Seat{}}
+
+// New issues a fresh token for the given seat.
+func (s *Sessions) New(match *Match, side game.Side) string {
+	raw := make([]byte, 16)
+
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+
+	token := hex.EncodeToString(raw)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.seats[token] = Seat{match, side}
+
+	return token
+}
+
+func (s *Sessions) Get(token string) (Seat, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seat, ok := s.seats[token]
+
+	return seat, ok
+}
+
+func (s *Sessions) Remove(token string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.seats, token)
+}
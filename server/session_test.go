@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+// dial runs Handshake on a fresh connection that says hello, and returns a
+// channel of everything the server answers.
+func dial(t *testing.T, hello *protocol.Hello) chan protocol.Message {
+	server, client := net.Pipe()
+
+	go Handshake(server)
+
+	received := make(chan protocol.Message, 16)
+
+	go func() {
+		dec := protocol.NewDecoder(client)
+
+		for {
+			msg, err := dec.Decode()
+
+			if err != nil {
+				close(received)
+				return
+			}
+
+			received <- msg
+		}
+	}()
+
+	if err := protocol.NewEncoder(client).Encode(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { client.Close() })
+
+	return received
+}
+
+// closed waits for the server to hang up on received.
+func closed(t *testing.T, received chan protocol.Message) {
+	t.Helper()
+
+	for {
+		select {
+		case _, ok := <-received:
+			if !ok {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("connection still open")
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	m := NewMatch("SEAT", game.Classic, game.TimeControl{})
+	defer func() {
+		m.mutex.Lock()
+		m.stop()
+		m.mutex.Unlock()
+	}()
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	token := seated(t, xMsgs).Token
+	m.Move(game.X, 4)
+
+	// The old connection has not noticed it dropped yet.
+	resumed := dial(t, &protocol.Hello{Name: "maria", Token: token})
+
+	if assign := seated(t, resumed); assign.Side != 0 || assign.Token != token ||
+		assign.Room != "SEAT" {
+		t.Errorf("resumed as %#v, want side 0 of SEAT", assign)
+	}
+
+	next[*protocol.Ready](t, resumed)
+
+	if state := next[*protocol.State](t, resumed); state.Cells[4] != 0 || state.Turn != 1 {
+		t.Errorf("resumed on %#v, want the board so far", state)
+	}
+
+	closed(t, xMsgs)
+
+	m.Move(game.O, 0)
+	m.Move(game.X, 8)
+
+	// Both see the moves of the resumed seat, next skips the states before.
+	for _, msgs := range []chan protocol.Message{oMsgs, resumed} {
+		for state := next[*protocol.State](t, msgs); state.Cells[8] != 0; {
+			state = next[*protocol.State](t, msgs)
+		}
+	}
+
+	stranger := dial(t, &protocol.Hello{Token: "not" + token})
+
+	if e := next[*protocol.Error](t, stranger); e.Text != "unknown session" {
+		t.Errorf("resuming with a wrong token: %q", e.Text)
+	}
+
+	closed(t, stranger)
+}
+
+func TestAbsent(t *testing.T) {
+	m := NewMatch("GONE", game.Classic, game.TimeControl{})
+	m.resume = 50 * time.Millisecond
+
+	x, xMsgs := connect(t, "maria", game.Classic)
+	o, oMsgs := connect(t, "jose", game.Classic)
+
+	m.Join(x)
+	m.Join(o)
+
+	token := seated(t, xMsgs).Token
+	m.Leave(game.X, x)
+
+	closed(t, oMsgs)
+
+	if !m.Stopped() {
+		t.Error("match still running after the player stayed away")
+	}
+
+	if _, ok := sessions.Get(token); ok {
+		t.Error("session of an abandoned match can still be resumed")
+	}
+
+	late := dial(t, &protocol.Hello{Token: token})
+
+	if e := next[*protocol.Error](t, late); e.Text != "unknown session" {
+		t.Errorf("resuming after the timeout: %q", e.Text)
+	}
+}
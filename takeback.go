@@ -0,0 +1,148 @@
+package main
+
+import (
+	"cardgame/protocol"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// offered is set while the opponent waits for us to answer its takeback.
+var offered bool
+
+// notice is a line of news about the game, shown under the board until
+// it runs out.
+var notice struct {
+	text  string
+	until time.Time
+}
+
+// noticeDuration is how long a notice stays up.
+const noticeDuration = 2 * time.Second
+
+func Notify(text string) {
+	notice.text, notice.until = text, time.Now().Add(noticeDuration)
+}
+
+// DrawNotice draws the notice, if there is one, centered under the board.
+func DrawNotice() {
+	if notice.text == "" || time.Now().After(notice.until) {
+		return
+	}
+
+	style := defaultStyle
+	style.align = AlignCenter
+	style.color = vec4{1, 1, 0, 1}
+
+	DrawString(vec2{W / 2, H - 20}, notice.text, style)
+}
+
+// Takeback takes back our last move: right away against the AI, along with
+// its reply, or online by asking the opponent.
+func Takeback() {
+	switch {
+	case !ready || Spectating():
+	case offline:
+		LocalStep(-1)
+	default:
+		Request(&protocol.Takeback{})
+		Notify("Takeback asked")
+	}
+}
+
+// Redo plays a move taken back again, against the AI only.
+func Redo() {
+	if ready && offline {
+		LocalStep(1)
+	}
+}
+
+// The answers of the takeback scene, left to right.
+const (
+	takebackAccept = iota
+	takebackDecline
+)
+
+// TakebackScene asks over the board whether to let the opponent take back
+// its last move. It goes away once answered, or when the opponent moves on
+// without waiting for an answer.
+type TakebackScene struct {
+	buttons []Button
+	focus   Focus
+}
+
+func (s *TakebackScene) Enter() {
+	s.focus = Focus{takebackAccept}
+	s.buttons = []Button{
+		&SimpleButton{ButtonData: ButtonData{vec2{W/2 - 52, H/2 + 2}, vec2{48, 12}}},
+		&SimpleButton{ButtonData: ButtonData{vec2{W/2 + 4, H/2 + 2}, vec2{48, 12}}},
+	}
+
+	s.focus.Apply(s.buttons)
+}
+
+func (s *TakebackScene) Exit() {}
+
+func (s *TakebackScene) Update() {
+	if !ready || !offered {
+		scenes.Pop()
+	}
+}
+
+// Answer tells the server whether the takeback is accepted.
+func (s *TakebackScene) Answer(accept bool) {
+	offered = false
+	Request(&protocol.TakebackReply{Accept: accept})
+	scenes.Pop()
+}
+
+func (s *TakebackScene) Draw() {
+	DrawBoard()
+
+	renderer.Bind(defaultTexture)
+	renderer.Draw(getModel(vec2{0, H/2 - 16}, vec2{W, 34}),
+		defaultTexture.Coords(vec4{0, 16, 16, 16}), vec4{0, 0, 0, .75})
+
+	for i := range s.buttons {
+		s.buttons[i].Draw()
+	}
+
+	renderer.Bind(fontTexture)
+
+	center := defaultStyle
+	center.align = AlignCenter
+
+	DrawString(vec2{W / 2, H/2 - 12}, "Take back their move?", center)
+	DrawString(vec2{W/2 - 28, H/2 + 4}, "Accept", center)
+	DrawString(vec2{W/2 + 28, H/2 + 4}, "Decline", center)
+}
+
+func (s *TakebackScene) Event(event sdl.Event, action Action) {
+	if _, ok := event.(*sdl.MouseMotionEvent); ok {
+		s.focus.Set(CheckButtonPress(player.pos, s.buttons), s.buttons)
+	}
+
+	switch action {
+	case ActionLeft:
+		s.focus.Set(takebackAccept, s.buttons)
+	case ActionRight:
+		s.focus.Set(takebackDecline, s.buttons)
+	case ActionPlaceMark:
+		choice := s.focus.cell
+
+		if spot, ok := clicked(event); ok {
+			choice = CheckButtonPress(spot, s.buttons)
+		}
+
+		switch choice {
+		case takebackAccept:
+			s.Answer(true)
+		case takebackDecline:
+			s.Answer(false)
+		}
+	case ActionUndo:
+		s.Answer(false)
+	case ActionQuit:
+		ToMenu()
+	}
+}
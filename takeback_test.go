@@ -0,0 +1,98 @@
+package main
+
+import (
+	"cardgame/game"
+	"cardgame/protocol"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestStep(t *testing.T) {
+	local := game.New(game.Classic, game.X)
+	local.Play(game.X, 4)
+	local.Play(game.O, 0)
+
+	if !Step(local, game.X, -1) || len(local.History()) != 0 || local.Turn() != game.X {
+		t.Errorf("undo left %v, turn %v", local.History(), local.Turn())
+	}
+
+	if !Step(local, game.X, 1) || !reflect.DeepEqual(local.History(), []int{4, 0}) {
+		t.Errorf("redo left %v, want the move and the reply", local.History())
+	}
+
+	// The AI moved first, so its opening move stays.
+	local = game.New(game.Classic, game.O)
+	local.Play(game.O, 4)
+	local.Play(game.X, 0)
+	local.Play(game.O, 8)
+
+	if !Step(local, game.X, -1) || !reflect.DeepEqual(local.History(), []int{4}) {
+		t.Errorf("undo left %v, want the AI's opening move", local.History())
+	}
+
+	if Step(local, game.X, -1) {
+		t.Error("took back the AI's opening move")
+	}
+
+	if !Step(local, game.X, 1) || local.Turn() != game.X || len(local.History()) != 3 {
+		t.Errorf("redo left %v, turn %v", local.History(), local.Turn())
+	}
+}
+
+func TestTakebackScene(t *testing.T) {
+	record(t)
+
+	server, client := net.Pipe()
+	defer server.Close()
+
+	encoder = protocol.NewEncoder(client)
+	ready, offered = true, true
+	turnClocks = NewTurnClocks(game.TimeControl{})
+
+	defer func() { encoder, ready, offered = nil, false, false }()
+
+	SetRules(game.Classic)
+
+	scenes = SceneStack{}
+	scenes.Push(&GameScene{})
+	scenes.Update()
+
+	if _, ok := scenes.Top().(*TakebackScene); !ok {
+		t.Fatalf("an offered takeback shows %T, want *TakebackScene", scenes.Top())
+	}
+
+	scenes.Event(&sdl.KeyboardEvent{Type: sdl.KEYDOWN}, ActionPlaceMark)
+
+	if _, ok := scenes.Top().(*GameScene); !ok || offered {
+		t.Errorf("answering left %T on top, offered %v", scenes.Top(), offered)
+	}
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+
+	msg, err := protocol.NewDecoder(server).Decode()
+
+	if reply, ok := msg.(*protocol.TakebackReply); err != nil || !ok || !reply.Accept {
+		t.Errorf("sent %#v, %v, want an accepted TakebackReply", msg, err)
+	}
+}
+
+func TestLocalChannel(t *testing.T) {
+	inbox, done := make(chan event, inboxSize), make(chan struct{})
+	defer close(done)
+
+	go LocalChannel(game.Rules{Size: 4, K: 3}, game.X, game.Random, inbox, done)
+
+	if start, ok := (<-inbox).msg.(*protocol.Ready); !ok || start.Size != 4 || start.K != 3 {
+		t.Errorf("first event %#v, want the rules", start)
+	}
+
+	state, ok := (<-inbox).msg.(*protocol.State)
+
+	if !ok || len(state.Cells) != 16 || state.Winner != -1 || state.Turn != 0 {
+		t.Errorf("second event %#v, want an empty 4 by 4 board", state)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// glyphLayout lists the characters of font.png in the order of its 8x8
+// cells, left to right and top to bottom. The digits come first so that the
+// original digit-only sheet still lines up.
+const glyphLayout = "0123456789" +
+	" !\"#$%&'()*+,-./:;<=>?@" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`" +
+	"abcdefghijklmnopqrstuvwxyz{|}~"
+
+const glyphSize = 8
+
+// glyphs maps each printable ASCII character to its cell in font.png.
+var glyphs = map[rune]vec4{}
+
+// SetFont makes font the texture text is drawn from, its glyphs filling as
+// many columns as it is wide. A texture too small to hold them all is an
+// error rather than garbled text.
+func SetFont(font Texture) error {
+	columns := int(font.w) / glyphSize
+
+	if columns == 0 || (len(glyphLayout)+columns-1)/columns*glyphSize > int(font.h) {
+		return fmt.Errorf("a %dx%d font can not hold %d glyphs of %d pixels", font.w,
+			font.h, len(glyphLayout), glyphSize)
+	}
+
+	glyphs = map[rune]vec4{}
+
+	for i, c := range glyphLayout {
+		glyphs[c] = vec4{
+			float32(i%columns) * glyphSize,
+			float32(i/columns) * glyphSize,
+			glyphSize,
+			glyphSize,
+		}
+	}
+
+	fontTexture = font
+
+	return nil
+}
+
+// Glyph returns the font.png cell for c, or the one for '?' if the font has
+// no such character.
+func Glyph(c rune) vec4 {
+	if rect, ok := glyphs[c]; ok {
+		return rect
+	}
+
+	return glyphs['?']
+}
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// TextStyle says how DrawString lays out a string: size is the width and
+// height of a glyph in pixels, 8 being the font's own size.
+type TextStyle struct {
+	size  float32
+	color vec4
+	align Align
+}
+
+var defaultStyle = TextStyle{size: glyphSize, color: vec4{1, 1, 1, 1}}
+
+func DrawText(pos vec2, size float32, c rune) {
+	DrawGlyph(pos, size, c, vec4{1, 1, 1, 1})
+}
+
+func DrawGlyph(pos vec2, size float32, c rune, color vec4) {
+	renderer.Draw(getModel(pos, vec2{size, size}), fontTexture.Coords(Glyph(c)),
+		color)
+}
+
+// MeasureText returns the width of the longest line and the height of all
+// lines of str.
+func MeasureText(str string, size float32) vec2 {
+	lines := strings.Split(str, "\n")
+	width := 0
+
+	for _, line := range lines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+
+	return vec2{float32(width) * size, float32(len(lines)) * size}
+}
+
+// DrawString draws str with its first line at pos.y. Depending on the
+// alignment pos.x is the left edge, the middle or the right edge of every
+// line. The font texture has to be bound.
+func DrawString(pos vec2, str string, style TextStyle) {
+	for i, line := range strings.Split(str, "\n") {
+		width := float32(len([]rune(line))) * style.size
+
+		x := pos.x
+
+		switch style.align {
+		case AlignCenter:
+			x -= width / 2
+		case AlignRight:
+			x -= width
+		}
+
+		y := pos.y + float32(i)*style.size
+
+		for _, c := range line {
+			DrawGlyph(vec2{x, y}, style.size, c, style.color)
+			x += style.size
+		}
+	}
+}
+
+// WrapText breaks str into lines no wider than width, at spaces where it can
+// and in the middle of words that are too long on their own.
+func WrapText(str string, width float32, size float32) []string {
+	columns := int(width / size)
+
+	if columns < 1 {
+		columns = 1
+	}
+
+	var lines []string
+
+	for _, paragraph := range strings.Split(str, "\n") {
+		line := []rune{}
+
+		for _, word := range strings.Fields(paragraph) {
+			runes := []rune(word)
+
+			if len(line) > 0 && len(line)+1+len(runes) > columns {
+				lines = append(lines, string(line))
+				line = line[:0]
+			}
+
+			for len(runes) > columns {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = line[:0]
+				}
+
+				lines = append(lines, string(runes[:columns]))
+				runes = runes[columns:]
+			}
+
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+
+			line = append(line, runes...)
+		}
+
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+// DrawTextBox wraps str to fit inside the box at pos and aligns each line
+// within it. Lines that do not fit below the box are dropped. It returns the
+// size the text took.
+func DrawTextBox(pos vec2, size vec2, str string, style TextStyle) vec2 {
+	lines := WrapText(str, size.x, style.size)
+
+	if rows := int(size.y / style.size); len(lines) > rows {
+		lines = lines[:rows]
+	}
+
+	switch style.align {
+	case AlignCenter:
+		pos.x += size.x / 2
+	case AlignRight:
+		pos.x += size.x
+	}
+
+	text := strings.Join(lines, "\n")
+
+	DrawString(pos, text, style)
+
+	return MeasureText(text, style.size)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"cardgame/clock"
+	"math"
+	"sync"
+)
+
+// Easing maps the linear progress of a tween, from 0 to 1, to how far along
+// the value is. Most start at 0 and end at 1 but may overshoot in between.
+type Easing func(t float32) float32
+
+func Linear(t float32) float32 {
+	return t
+}
+
+func EaseInQuad(t float32) float32 {
+	return t * t
+}
+
+func EaseOutQuad(t float32) float32 {
+	return t * (2 - t)
+}
+
+func EaseInOutQuad(t float32) float32 {
+	if t < .5 {
+		return 2 * t * t
+	}
+
+	return -1 + (4-2*t)*t
+}
+
+func EaseOutCubic(t float32) float32 {
+	t--
+	return t*t*t + 1
+}
+
+func EaseInOutSine(t float32) float32 {
+	return float32(-(math.Cos(math.Pi*float64(t)) - 1) / 2)
+}
+
+// EaseOutBack overshoots the end a little before settling on it.
+func EaseOutBack(t float32) float32 {
+	const c1 = 1.70158
+	const c3 = c1 + 1
+
+	t--
+	return 1 + c3*t*t*t + c1*t*t
+}
+
+// Animation is anything that changes over time. Update moves it dt seconds
+// further, a fixed simulation step, and Apply shows it alpha of the way
+// from the step before to the last one. Reset takes it back to the start.
+type Animation interface {
+	Update(dt float32)
+	Apply(alpha float32)
+	Done() bool
+	Reset()
+}
+
+// Tween drives a value from 0 to 1 over duration seconds and hands the eased
+// progress to apply. The config takes the clock.Timer flags: TWO_WAY goes
+// back to 0 once it reaches 1 and LOOP starts over instead of finishing.
+type Tween struct {
+	duration, elapsed float32
+	config            uint32
+	ease              Easing
+	apply             func(t float32)
+	backward, done    bool
+
+	// last and value are the eased progress before and after the last step,
+	// wrapped is set when a LOOP started over in between.
+	last, value float32
+	wrapped     bool
+}
+
+func NewTween(duration float32, ease Easing, config uint32, apply func(t float32)) *Tween {
+	t := &Tween{duration: duration, config: config, ease: ease, apply: apply}
+	t.Reset()
+
+	return t
+}
+
+// TweenFloat animates *target from one value to another.
+func TweenFloat(target *float32, from, to float32, duration float32, ease Easing, config uint32) *Tween {
+	return NewTween(duration, ease, config, func(t float32) {
+		*target = from + (to-from)*t
+	})
+}
+
+func TweenVec2(target *vec2, from, to vec2, duration float32, ease Easing, config uint32) *Tween {
+	return NewTween(duration, ease, config, func(t float32) {
+		*target = from.Add(to.Sub(from).Mul(vec2{t, t}))
+	})
+}
+
+func TweenVec4(target *vec4, from, to vec4, duration float32, ease Easing, config uint32) *Tween {
+	return NewTween(duration, ease, config, func(t float32) {
+		*target = LerpVec4(from, to, t)
+	})
+}
+
+func LerpVec4(from, to vec4, t float32) vec4 {
+	return from.Add(to.Sub(from).Mul(vec4{t, t, t, t}))
+}
+
+// Delay is a tween that changes nothing, to hold a sequence up.
+func Delay(duration float32) *Tween {
+	return NewTween(duration, Linear, clock.SIMPLE, func(float32) {})
+}
+
+func (t *Tween) Update(dt float32) {
+	if t.done {
+		return
+	}
+
+	t.last = t.value
+	t.wrapped = false
+	t.elapsed += dt
+
+	for t.elapsed >= t.duration {
+		if t.config&clock.TWO_WAY > 0 && !t.backward {
+			t.backward = true
+		} else if t.config&clock.LOOP > 0 && t.duration > 0 {
+			t.backward = false
+			t.wrapped = t.config&clock.TWO_WAY == 0
+		} else {
+			t.elapsed = t.duration
+			t.done = true
+			break
+		}
+
+		t.elapsed -= t.duration
+	}
+
+	t.value = t.Value()
+	t.apply(t.value)
+}
+
+func (t *Tween) Apply(alpha float32) {
+	if t.wrapped {
+		t.apply(t.value)
+		return
+	}
+
+	t.apply(t.last + (t.value-t.last)*alpha)
+}
+
+// Value is the eased progress, what was last handed to apply.
+func (t *Tween) Value() float32 {
+	progress := float32(1)
+
+	if t.duration > 0 {
+		progress = t.elapsed / t.duration
+	}
+
+	if t.backward {
+		progress = 1 - progress
+	}
+
+	return t.ease(progress)
+}
+
+func (t *Tween) Done() bool {
+	return t.done
+}
+
+func (t *Tween) Reset() {
+	t.elapsed, t.backward, t.done, t.wrapped = 0, false, false, false
+	t.value = t.Value()
+	t.last = t.value
+	t.apply(t.value)
+}
+
+// Sequence plays its animations one after the other.
+type Sequence struct {
+	items []Animation
+	index int
+}
+
+func NewSequence(items ...Animation) *Sequence {
+	return &Sequence{items: items}
+}
+
+func (s *Sequence) Update(dt float32) {
+	for s.index < len(s.items) {
+		s.items[s.index].Update(dt)
+
+		if !s.items[s.index].Done() {
+			return
+		}
+
+		s.index++
+		dt = 0
+	}
+}
+
+// Apply only shows the current item, the ones before it are finished.
+func (s *Sequence) Apply(alpha float32) {
+	if s.index < len(s.items) {
+		s.items[s.index].Apply(alpha)
+	}
+}
+
+func (s *Sequence) Done() bool {
+	return s.index >= len(s.items)
+}
+
+// Reset resets the items back to front so that the first one is the last
+// to apply its starting value.
+func (s *Sequence) Reset() {
+	for i := len(s.items) - 1; i >= 0; i-- {
+		s.items[i].Reset()
+	}
+
+	s.index = 0
+}
+
+// Parallel plays its animations at the same time and is done when all of
+// them are.
+type Parallel struct {
+	items []Animation
+}
+
+func NewParallel(items ...Animation) *Parallel {
+	return &Parallel{items: items}
+}
+
+func (p *Parallel) Update(dt float32) {
+	for _, item := range p.items {
+		item.Update(dt)
+	}
+}
+
+func (p *Parallel) Apply(alpha float32) {
+	for _, item := range p.items {
+		item.Apply(alpha)
+	}
+}
+
+func (p *Parallel) Done() bool {
+	for _, item := range p.items {
+		if !item.Done() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Parallel) Reset() {
+	for _, item := range p.items {
+		item.Reset()
+	}
+}
+
+// Animations is the set of animations the main loop plays. Finished ones are
+// dropped.
+type Animations struct {
+	mutex sync.Mutex
+	list  []Animation
+}
+
+var animations Animations
+
+func (a *Animations) Add(animation Animation) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.list = append(a.list, animation)
+}
+
+func (a *Animations) Remove(animation Animation) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i := range a.list {
+		if a.list[i] == animation {
+			a.list = append(a.list[:i], a.list[i+1:]...)
+			return
+		}
+	}
+}
+
+func (a *Animations) Update(dt float32) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	playing := a.list[:0]
+
+	for _, animation := range a.list {
+		animation.Update(dt)
+
+		if !animation.Done() {
+			playing = append(playing, animation)
+		}
+	}
+
+	for i := len(playing); i < len(a.list); i++ {
+		a.list[i] = nil
+	}
+
+	a.list = playing
+}
+
+// Apply shows every animation alpha of the way into the step, right before
+// the frame is drawn.
+func (a *Animations) Apply(alpha float32) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, animation := range a.list {
+		animation.Apply(alpha)
+	}
+}
+
+// Len is how many animations are playing.
+func (a *Animations) Len() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return len(a.list)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"cardgame/clock"
+	"cardgame/game"
+	"math"
+	"testing"
+)
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestTween(t *testing.T) {
+	tests := []struct {
+		name   string
+		config uint32
+		steps  []float32
+		want   []float32
+		done   []bool
+	}{
+		{"simple", clock.SIMPLE, []float32{.5, .25, .5}, []float32{5, 7.5, 10}, []bool{false, false, true}},
+		{"two way", clock.TWO_WAY, []float32{.5, .5, .25, .75}, []float32{5, 10, 7.5, 0}, []bool{false, false, false, true}},
+		{"loop", clock.LOOP, []float32{.75, .5, 1}, []float32{7.5, 2.5, 2.5}, []bool{false, false, false}},
+		{"ping pong", clock.TWO_WAY | clock.LOOP, []float32{.75, .5, 1}, []float32{7.5, 7.5, 2.5}, []bool{false, false, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := float32(-1)
+			tween := TweenFloat(&value, 0, 10, 1, Linear, test.config)
+
+			if value != 0 {
+				t.Fatalf("new tween left the value at %v, want 0", value)
+			}
+
+			for i, dt := range test.steps {
+				tween.Update(dt)
+
+				if !near(value, test.want[i]) || tween.Done() != test.done[i] {
+					t.Errorf("step %d: got %v done %v, want %v done %v", i, value,
+						tween.Done(), test.want[i], test.done[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSequenceAndParallel(t *testing.T) {
+	var a, b float32
+
+	sequence := NewSequence(TweenFloat(&a, 0, 1, 1, Linear, clock.SIMPLE),
+		TweenFloat(&b, 0, 1, 1, Linear, clock.SIMPLE))
+	sequence.Reset()
+
+	sequence.Update(.5)
+
+	if !near(a, .5) || b != 0 {
+		t.Errorf("half way through the first tween got %v %v", a, b)
+	}
+
+	sequence.Update(.5)
+	sequence.Update(.5)
+
+	if a != 1 || !near(b, .5) || sequence.Done() {
+		t.Errorf("half way through the second tween got %v %v done %v", a, b,
+			sequence.Done())
+	}
+
+	parallel := NewParallel(TweenFloat(&a, 0, 1, 1, Linear, clock.SIMPLE),
+		TweenFloat(&b, 0, 1, 2, Linear, clock.SIMPLE))
+
+	parallel.Update(1)
+
+	if a != 1 || !near(b, .5) || parallel.Done() {
+		t.Errorf("got %v %v done %v, want 1 .5 not done", a, b, parallel.Done())
+	}
+
+	parallel.Update(1)
+
+	if !parallel.Done() {
+		t.Error("parallel not done after its longest tween")
+	}
+}
+
+func TestEasingEnds(t *testing.T) {
+	easings := map[string]Easing{
+		"linear": Linear, "in quad": EaseInQuad, "out quad": EaseOutQuad,
+		"in out quad": EaseInOutQuad, "out cubic": EaseOutCubic,
+		"in out sine": EaseInOutSine, "out back": EaseOutBack,
+	}
+
+	for name, ease := range easings {
+		if !near(ease(0), 0) || !near(ease(1), 1) {
+			t.Errorf("%s goes from %v to %v, want 0 to 1", name, ease(0), ease(1))
+		}
+	}
+}
+
+func TestApplyStateAnimatesMoves(t *testing.T) {
+	record(t)
+
+	animations = Animations{}
+	SetRules(game.Classic)
+
+	ApplyState(0, 0, cells(
+		0, 1, -1,
+		-1, -1, 1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	if animations.Len() != 0 {
+		t.Fatalf("a jump of three marks started %d animations", animations.Len())
+	}
+
+	ApplyState(0, 0, cells(
+		0, 1, -1,
+		-1, 0, 1,
+		-1, -1, -1,
+	), game.X, -1)
+
+	if button := engine.buttons[4].(*SimpleButton); animations.Len() != 1 || button.shrink != 1 {
+		t.Fatalf("placing a mark started %d animations, shrink %v", animations.Len(),
+			button.shrink)
+	}
+
+	ApplyState(1, 0, cells(
+		0, 1, -1,
+		-1, 0, 1,
+		-1, -1, 0,
+	), game.X, -1)
+
+	corner := engine.buttons[8].(*SimpleButton)
+
+	if corner.color == (vec4{1, 1, 0, 1}) {
+		t.Error("last cell of the winning line lit up before the sweep got to it")
+	}
+
+	for i := 0; i < 20; i++ {
+		animations.Update(.05)
+	}
+
+	if animations.Len() != 0 || corner.shrink != 0 || corner.color != (vec4{1, 1, 0, 1}) {
+		t.Errorf("after a second %d animations left, shrink %v color %v",
+			animations.Len(), corner.shrink, corner.color)
+	}
+}
+
+func TestTweenApply(t *testing.T) {
+	value := float32(0)
+	tween := TweenFloat(&value, 0, 10, 1, Linear, clock.LOOP)
+
+	tween.Update(.5)
+	tween.Apply(.5)
+
+	if !near(value, 2.5) {
+		t.Errorf("half way into the first step got %v, want 2.5", value)
+	}
+
+	tween.Update(.25)
+	tween.Apply(.2)
+
+	if !near(value, 5.5) {
+		t.Errorf("a fifth into the second step got %v, want 5.5", value)
+	}
+
+	tween.Update(.5)
+	tween.Apply(.5)
+
+	if !near(value, 2.5) {
+		t.Errorf("a step over the loop got %v, want the new value 2.5", value)
+	}
+}
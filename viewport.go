@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Scaling says how the W by H world is blown up to fill the window.
+type Scaling int
+
+const (
+	// ScaleFit uses the biggest scale that fits, fractions included.
+	ScaleFit Scaling = iota
+	// ScaleInteger only uses whole scales so pixels stay square and even,
+	// unless the window is smaller than the world.
+	ScaleInteger
+)
+
+var scalingNames = []string{"fit", "integer"}
+
+func (s Scaling) String() string {
+	if s < 0 || int(s) >= len(scalingNames) {
+		return fmt.Sprintf("scaling(%d)", int(s))
+	}
+
+	return scalingNames[s]
+}
+
+func ParseScaling(str string) (Scaling, error) {
+	for i, name := range scalingNames {
+		if name == str {
+			return Scaling(i), nil
+		}
+	}
+
+	return ScaleFit, fmt.Errorf("unknown scaling %q, want fit or integer", str)
+}
+
+// Viewport places the world inside the window, keeping its aspect ratio and
+// filling what is left with bars. Mouse events come in window coordinates
+// while GL works in drawable pixels, which are more on HiDPI screens, so
+// both sizes are tracked.
+type Viewport struct {
+	scaling Scaling
+
+	window, drawable vec2
+
+	// scale is drawable pixels per world unit and offset is where the world
+	// starts in drawable pixels, from the top left.
+	scale  float32
+	offset vec2
+}
+
+// Resize fits the world to a window of the given size in window coordinates
+// and in drawable pixels.
+func (v *Viewport) Resize(windowW, windowH, drawableW, drawableH int32) {
+	v.window = vec2{float32(windowW), float32(windowH)}
+	v.drawable = vec2{float32(drawableW), float32(drawableH)}
+
+	scale := math.Min(float64(drawableW)/W, float64(drawableH)/H)
+
+	if v.scaling == ScaleInteger && scale >= 1 {
+		scale = math.Floor(scale)
+	}
+
+	v.scale = float32(scale)
+
+	size := v.Size()
+
+	v.offset = vec2{
+		float32(math.Floor(float64(v.drawable.x-size.x) / 2)),
+		float32(math.Floor(float64(v.drawable.y-size.y) / 2)),
+	}
+}
+
+// Size is the world size in drawable pixels.
+func (v Viewport) Size() vec2 {
+	return vec2{W * v.scale, H * v.scale}
+}
+
+// Apply points GL at the part of the drawable the world is in.
+func (v Viewport) Apply() {
+	size := v.Size()
+
+	gl.Viewport(int32(v.offset.x), int32(v.drawable.y-v.offset.y-size.y),
+		int32(size.x), int32(size.y))
+}
+
+// density is drawable pixels per window coordinate.
+func (v Viewport) density() vec2 {
+	if v.window.x == 0 || v.window.y == 0 {
+		return vec2{1, 1}
+	}
+
+	return v.drawable.Div(v.window)
+}
+
+// ToWorld turns a point in window coordinates, as mouse events have them,
+// into world coordinates. Points on the bars land outside 0..W and 0..H.
+func (v Viewport) ToWorld(spot vec2) vec2 {
+	if v.scale == 0 {
+		return spot
+	}
+
+	pixels := spot.Mul(v.density())
+
+	return pixels.Sub(v.offset).Div(vec2{v.scale, v.scale})
+}
+
+// ToWindow is the inverse of ToWorld.
+func (v Viewport) ToWindow(pos vec2) vec2 {
+	if v.scale == 0 {
+		return pos
+	}
+
+	pixels := pos.Mul(vec2{v.scale, v.scale}).Add(v.offset)
+
+	return pixels.Div(v.density())
+}
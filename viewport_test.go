@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestViewport(t *testing.T) {
+	tests := []struct {
+		name             string
+		scaling          Scaling
+		window, drawable vec2
+		scale            float32
+		offset           vec2
+	}{
+		{"exact", ScaleFit, vec2{1920, 1080}, vec2{1920, 1080}, 6, vec2{0, 0}},
+		{"letterbox", ScaleFit, vec2{1000, 700}, vec2{1000, 700}, 3.125, vec2{0, 68}},
+		{"pillarbox", ScaleFit, vec2{800, 180}, vec2{800, 180}, 1, vec2{240, 0}},
+		{"integer", ScaleInteger, vec2{1000, 700}, vec2{1000, 700}, 3, vec2{20, 80}},
+		{"integer shrink", ScaleInteger, vec2{160, 90}, vec2{160, 90}, .5, vec2{0, 0}},
+		{"hidpi", ScaleFit, vec2{640, 360}, vec2{1280, 720}, 4, vec2{0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := Viewport{scaling: test.scaling}
+			v.Resize(int32(test.window.x), int32(test.window.y),
+				int32(test.drawable.x), int32(test.drawable.y))
+
+			if v.scale != test.scale || v.offset != test.offset {
+				t.Errorf("got scale %v offset %v, want %v %v", v.scale, v.offset,
+					test.scale, test.offset)
+			}
+
+			for _, world := range []vec2{{0, 0}, {W, H}, {100, 50}} {
+				if back := v.ToWorld(v.ToWindow(world)); back != world {
+					t.Errorf("%v went through the window and came back as %v", world, back)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckButtonPressScaled(t *testing.T) {
+	record(t)
+
+	button := &SimpleButton{ButtonData: ButtonData{vec2{100, 50}, vec2{16, 16}}}
+	buttons := []Button{button}
+
+	engine.viewport.Resize(1000, 700, 1000, 700)
+
+	inside := engine.viewport.ToWindow(vec2{108, 58})
+	outside := engine.viewport.ToWindow(vec2{99, 58})
+
+	if got := CheckButtonPress(inside, buttons); got != 0 {
+		t.Errorf("click at %v missed the button", inside)
+	}
+
+	if got := CheckButtonPress(outside, buttons); got != -1 {
+		t.Errorf("click at %v hit the button", outside)
+	}
+
+	// Without the letterbox offset this would land on the button.
+	if got := CheckButtonPress(vec2{108 * 3.125, 58 * 3.125}, buttons); got != -1 {
+		t.Error("click on the letterbox bar hit a button")
+	}
+}